@@ -17,15 +17,21 @@ package main
 import (
 	"encoding/base64"
 	"fmt"
+	"os"
 	"regexp"
 	"strings"
 
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
 	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
 	"github.com/krm-functions/catalog/pkg/api"
 	"github.com/krm-functions/catalog/pkg/helm"
 	t "github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/krm-functions/catalog/pkg/util"
 	"github.com/krm-functions/catalog/pkg/version"
 	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
@@ -33,6 +39,11 @@ const (
 	containerImagePathFilter     = `.*containers\[\d+\].image$`
 	initContainerImagePathFilter = `.*initContainers\[\d+\].image$`
 	digesterRegexpPrefix         = `# digester: `
+
+	// ImageLayoutDirEnv is the environment variable defining a local OCI image layout used for resolving digests
+	ImageLayoutDirEnv = "IMAGE_LAYOUT_DIR"
+	// layoutRefNameAnnotation holds the image reference of images in an OCI image layout
+	layoutRefNameAnnotation = "org.opencontainers.image.ref.name"
 )
 
 type ImageFilter struct {
@@ -44,6 +55,12 @@ type ImageFilter struct {
 
 	// Regular expressions used to identify images
 	PathFilters []*regexp.Regexp
+
+	// Map from image (key) to digest (value) of images in a local OCI image layout
+	LayoutDigests map[string]string
+
+	// Resolve digests only from LayoutDigests
+	Offline bool
 }
 
 func NewImageFilter() *ImageFilter {
@@ -58,6 +75,14 @@ func (i *ImageFilter) Process(resourceList *framework.ResourceList) error {
 	results = append(results, &framework.Result{
 		Message: "digester",
 	})
+	pkgObjects, err := toKubeObjects(resourceList.Items)
+	if err != nil {
+		return err
+	}
+	layoutDigests, err := LoadImageLayout(os.Getenv(ImageLayoutDirEnv))
+	if err != nil {
+		return err
+	}
 	for _, iobj := range resourceList.Items {
 		if iobj.GetApiVersion() != api.HelmResourceAPIVersion || iobj.GetKind() != "RenderHelmChart" {
 			continue
@@ -80,7 +105,18 @@ func (i *ImageFilter) Process(resourceList *framework.ResourceList) error {
 			if len(chartTarball) == 0 {
 				return fmt.Errorf("no embedded chart found")
 			}
-			rendered, err := helm.Template(&spec.Charts[idx], chartTarball)
+			if err = helm.ApplyClusterProfile(&spec.Charts[idx], pkgObjects); err != nil {
+				return err
+			}
+			valuesFiles, err := helm.PackageValuesFiles(&spec.Charts[idx], iobj.GetAnnotations()[kioutil.PathAnnotation], pkgObjects)
+			if err != nil {
+				return err
+			}
+			rendered, _, err := helm.Template(&spec.Charts[idx], chartTarball, valuesFiles, pkgObjects)
+			if err != nil {
+				return err
+			}
+			rendered, err = helm.PostRender(&spec.Charts[idx], rendered)
 			if err != nil {
 				return err
 			}
@@ -89,11 +125,16 @@ func (i *ImageFilter) Process(resourceList *framework.ResourceList) error {
 				return err
 			}
 			imageFilter := NewImageFilter()
+			imageFilter.LayoutDigests = layoutDigests
+			imageFilter.Offline = util.OfflineMode()
 			_, err = imageFilter.Filter(objs)
 			if err != nil {
 				return err
 			}
-			imageFilter.LookupDigests()
+			err = imageFilter.LookupDigests()
+			if err != nil {
+				return err
+			}
 			for _, image := range imageFilter.Images {
 				results = append(results, &framework.Result{
 					Message:  fmt.Sprintf("image: %v\n", image+"@"+imageFilter.Digests[image]),
@@ -130,17 +171,74 @@ func (i *ImageFilter) VisitScalar(node *yaml.RNode, path string) error {
 	return nil
 }
 
-func (i *ImageFilter) LookupDigests() {
+// LookupDigests resolves the digests of images, first from the local
+// OCI image layout and then from registries. In offline mode, images
+// not found in the local OCI image layout are an error
+func (i *ImageFilter) LookupDigests() error {
 	for _, image := range i.Images {
 		if strings.Contains(image, "@") {
 			continue
 		}
+		if digest, found := i.LayoutDigests[imageName(image)]; found {
+			i.Digests[image] = digest
+			continue
+		}
+		if i.Offline {
+			return fmt.Errorf("offline mode: image %v not found in OCI image layout %q (%s)", image, os.Getenv(ImageLayoutDirEnv), ImageLayoutDirEnv)
+		}
 		digest, err := crane.Digest(image, crane.WithUserAgent(fmt.Sprintf("digester/%s", version.Version)))
 		// We dont fail here if we cannot locate a digest, only if the digest is needed for a patch-back target
 		if err == nil {
 			i.Digests[image] = digest
 		}
 	}
+	return nil
+}
+
+// LoadImageLayout reads the digests of images in a local OCI image
+// layout, e.g. as written by 'crane pull --format=oci'. Images are
+// identified by the 'org.opencontainers.image.ref.name' annotation
+func LoadImageLayout(dir string) (map[string]string, error) {
+	digests := map[string]string{}
+	if dir == "" {
+		return digests, nil
+	}
+	idx, err := layout.ImageIndexFromPath(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading OCI image layout %v: %w", dir, err)
+	}
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("reading OCI image layout %v: %w", dir, err)
+	}
+	for _, desc := range manifest.Manifests {
+		if ref, found := desc.Annotations[layoutRefNameAnnotation]; found {
+			digests[imageName(ref)] = desc.Digest.String()
+		}
+	}
+	return digests, nil
+}
+
+// imageName normalizes an image reference, e.g. 'nginx:1.2' to 'index.docker.io/library/nginx:1.2'
+func imageName(image string) string {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return image
+	}
+	return ref.Name()
+}
+
+// toKubeObjects converts resource list items such that they can be used with the kpt function SDK
+func toKubeObjects(items []*yaml.RNode) (fn.KubeObjects, error) {
+	objects := make(fn.KubeObjects, 0, len(items))
+	for _, item := range items {
+		o, err := fn.ParseKubeObject([]byte(item.MustString()))
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, o)
+	}
+	return objects, nil
 }
 
 type ImageDigestSetter struct {
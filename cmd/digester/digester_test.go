@@ -3,6 +3,9 @@ package main
 import (
 	"testing"
 
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/krm-functions/catalog/pkg/helm"
 	"github.com/stretchr/testify/assert"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
@@ -267,3 +270,41 @@ func assertDigest(t *testing.T, node *yaml.RNode, want string, path ...string) {
 	}
 	assert.Equal(t, want, yaml.GetValue(found))
 }
+
+func TestLookupDigestsOffline(t *testing.T) {
+	dir := t.TempDir()
+	p, err := layout.Write(dir, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.AppendImage(img, layout.WithAnnotations(map[string]string{layoutRefNameAnnotation: "index.docker.io/library/nginx:1.14.2"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	layoutDigests, err := LoadImageLayout(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imageFilter := NewImageFilter()
+	imageFilter.LayoutDigests = layoutDigests
+	imageFilter.Offline = true
+	imageFilter.Images = []string{"nginx:1.14.2"}
+	err = imageFilter.LookupDigests()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, digest.String(), imageFilter.Digests["nginx:1.14.2"])
+
+	imageFilter.Images = append(imageFilter.Images, "busybox:1.31")
+	err = imageFilter.LookupDigests()
+	assert.ErrorContains(t, err, "busybox:1.31")
+}
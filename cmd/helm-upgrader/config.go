@@ -15,7 +15,14 @@
 package main
 
 import (
+	"fmt"
+	"strconv"
+	"time"
+
 	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/krm-functions/catalog/pkg/api"
+	"github.com/krm-functions/catalog/pkg/semver"
+	"github.com/krm-functions/catalog/pkg/util"
 )
 
 type fnConfig struct {
@@ -23,11 +30,28 @@ type fnConfig struct {
 	AnnotateSumOnUpgradeAvailable bool `json:"annotateSumOnUpgradeAvailable,omitempty" yaml:"annotateSumOnUpgradeAvailable,omitempty"`
 	UpgradeOnUpgradeAvailable     bool `json:"upgradeOnUpgradeAvailable,omitempty" yaml:"upgradeOnUpgradeAvailable,omitempty"`
 	AnnotateCurrentSum            bool `json:"annotateCurrentSum,omitempty" yaml:"annotateCurrentSum,omitempty"`
+	Workers                       int  `json:"workers,omitempty" yaml:"workers,omitempty"`
+	// RenderDiff renders the current and new version of charts and reports the differences as results
+	RenderDiff bool `json:"renderDiff,omitempty" yaml:"renderDiff,omitempty"`
+	// ValuesCheck checks inline values against the new chart version, i.e. 'warn' or 'block'. Defaults to no check
+	ValuesCheck string `json:"valuesCheck,omitempty" yaml:"valuesCheck,omitempty"`
+	// Report is the format of the upgrade report, i.e. 'markdown' or 'json'. Defaults to no report
+	Report string `json:"report,omitempty" yaml:"report,omitempty"`
+	// ReportPath is the path in the package of the report ConfigMap
+	ReportPath string `json:"reportPath,omitempty" yaml:"reportPath,omitempty"`
+	// Policy is the default upgrade policy of charts, except for the constraint given per chart
+	Policy semver.Policy `json:"-" yaml:"-"`
 }
 
+// Legal values of the values check
+const (
+	ValuesCheckWarn  = "warn"
+	ValuesCheckBlock = "block"
+)
+
 var Config fnConfig
 
-func parseConfig(configmap *fn.KubeObject) {
+func parseConfig(configmap *fn.KubeObject) error {
 	if val, found, err := configmap.NestedBool("data", "annotateOnUpgradeAvailable"); err == nil && found {
 		Config.AnnotateOnUpgradeAvailable = val
 	}
@@ -42,4 +66,97 @@ func parseConfig(configmap *fn.KubeObject) {
 	if val, found, err := configmap.NestedBool("data", "annotateCurrentSum"); err == nil && found {
 		Config.AnnotateCurrentSum = val
 	}
+	workers, err := util.ConfigWorkers(configmap)
+	if err != nil {
+		return err
+	}
+	Config.Workers = workers
+	Config.RenderDiff = false
+	if val, found, err := configmap.NestedBool("data", "renderDiff"); err == nil && found {
+		Config.RenderDiff = val
+	}
+	Config.ValuesCheck = ""
+	if val, found, err := configmap.NestedString("data", "valuesCheck"); err == nil && found {
+		if val != ValuesCheckWarn && val != ValuesCheckBlock {
+			return fmt.Errorf("unsupported valuesCheck %q, supported: %v, %v", val, ValuesCheckWarn, ValuesCheckBlock)
+		}
+		Config.ValuesCheck = val
+	}
+	Config.Report = ""
+	if val, found, err := configmap.NestedString("data", "report"); err == nil && found {
+		if val != ReportMarkdown && val != ReportJSON {
+			return fmt.Errorf("unsupported report format %q, supported: %v, %v", val, ReportMarkdown, ReportJSON)
+		}
+		Config.Report = val
+	}
+	Config.ReportPath = defaultReportPath
+	if val, found, err := configmap.NestedString("data", "reportPath"); err == nil && found && val != "" {
+		Config.ReportPath = val
+	}
+	Config.Policy = semver.Policy{}
+	if val, found, err := configmap.NestedString("data", "maxDistance"); err == nil && found {
+		Config.Policy.MaxDistance = val
+	}
+	if val, found, err := configmap.NestedString("data", "minReleaseAge"); err == nil && found {
+		age, aErr := semver.ParseAge(val)
+		if aErr != nil {
+			return aErr
+		}
+		Config.Policy.MinReleaseAge = age
+	} else if days, found, err := configmap.NestedInt("data", "minReleaseAge"); err == nil && found {
+		Config.Policy.MinReleaseAge = time.Duration(days) * 24 * time.Hour
+	}
+	if val, found, err := configmap.NestedString("data", "denyVersions"); err == nil && found && val != "" {
+		Config.Policy.Deny = util.CsvToList(val)
+	}
+	if val, found, err := configmap.NestedBool("data", "prerelease"); err == nil && found {
+		Config.Policy.Prerelease = val
+	}
+	if val, found, err := configmap.NestedString("data", "versionScheme"); err == nil && found {
+		scheme, sErr := semver.ParseScheme(val)
+		if sErr != nil {
+			return sErr
+		}
+		Config.Policy.Scheme = scheme
+	}
+	return Config.Policy.Validate()
+}
+
+// chartPolicy returns the upgrade policy of a chart, i.e. the default
+// policy with the constraint of the chart and overrides given by
+// annotations of the chart spec resource
+func chartPolicy(kubeObject *fn.KubeObject, constraint string) (semver.Policy, error) {
+	policy := Config.Policy
+	policy.Constraint = constraint
+	if val := kubeObject.GetAnnotation(api.HelmResourceAnnotationUpgradeMaxDistance); val != "" {
+		policy.MaxDistance = val
+	}
+	if val := kubeObject.GetAnnotation(api.HelmResourceAnnotationUpgradeMinReleaseAge); val != "" {
+		age, err := semver.ParseAge(val)
+		if err != nil {
+			return policy, err
+		}
+		policy.MinReleaseAge = age
+	}
+	if val := kubeObject.GetAnnotation(api.HelmResourceAnnotationUpgradeDeny); val != "" {
+		policy.Deny = util.CsvToList(val)
+	}
+	if val := kubeObject.GetAnnotation(api.HelmResourceAnnotationUpgradePrerelease); val != "" {
+		prerelease, err := strconv.ParseBool(val)
+		if err != nil {
+			return policy, fmt.Errorf("parsing %v annotation: %w", api.HelmResourceAnnotationUpgradePrerelease, err)
+		}
+		policy.Prerelease = prerelease
+	}
+	if val := kubeObject.GetAnnotation(api.HelmResourceAnnotationUpgradeVersionScheme); val != "" {
+		scheme, err := semver.ParseScheme(val)
+		if err != nil {
+			return policy, fmt.Errorf("parsing %v annotation: %w", api.HelmResourceAnnotationUpgradeVersionScheme, err)
+		}
+		policy.Scheme = scheme
+	}
+	if err := policy.Validate(); err != nil {
+		return policy, fmt.Errorf("upgrade policy of %s: %w", kubeObject.GetName(), err)
+	}
+	return policy, nil
 }
@@ -0,0 +1,105 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/krm-functions/catalog/pkg/helm"
+	t "github.com/krm-functions/catalog/pkg/helmspecs"
+)
+
+// renderSpec is what is needed for rendering a chart as
+// render-helm-chart does, i.e. the chart spec with values and the
+// package values files and objects
+type renderSpec struct {
+	chart       t.HelmChart
+	valuesFiles map[string][]byte
+	objects     fn.KubeObjects
+}
+
+// newRenderSpec returns the render spec of a chart whose spec is in
+// kubeObject. The chart spec is copied, i.e. not modified
+func newRenderSpec(chart *t.HelmChart, kubeObject *fn.KubeObject, objects fn.KubeObjects) (*renderSpec, error) {
+	r := &renderSpec{chart: *chart, objects: objects}
+	if err := helm.ApplyClusterProfile(&r.chart, objects); err != nil {
+		return nil, err
+	}
+	valuesFiles, err := helm.PackageValuesFiles(&r.chart, kubeObject.PathAnnotation(), objects)
+	if err != nil {
+		return nil, err
+	}
+	r.valuesFiles = valuesFiles
+	return r, nil
+}
+
+// render renders a version of the chart. It does not modify the spec and is safe for concurrent use
+func (r *renderSpec) render(chartTarball []byte, version string) ([]byte, error) {
+	chart := r.chart
+	chart.Args.Version = version
+	rendered, _, err := helm.Template(&chart, chartTarball, r.valuesFiles, r.objects)
+	if err != nil {
+		return nil, fmt.Errorf("rendering version %v: %w", version, err)
+	}
+	return helm.PostRender(&chart, rendered)
+}
+
+// diff renders the current and new version of the chart and returns the differences
+func (r *renderSpec) diff(currTarball, newTarball []byte, newVersion string) ([]helm.ObjectDiff, error) {
+	curr, err := r.render(currTarball, r.chart.Args.Version)
+	if err != nil {
+		return nil, err
+	}
+	upgraded, err := r.render(newTarball, newVersion)
+	if err != nil {
+		return nil, err
+	}
+	return helm.DiffRendered(curr, upgraded)
+}
+
+// renderDiffResults returns the differences of rendering the new
+// version of a chart as results. Results reference the rendered
+// objects, with changed fields given by their current and proposed value
+func renderDiffResults(job *chartJob, ev *chartEvaluation) fn.Results {
+	var results fn.Results
+	prefix := fmt.Sprintf("chart %v %v -> %v: ", job.chart.Name, job.chart.Version, ev.newVersion.Version)
+	for _, d := range ev.renderDiff {
+		ref := &fn.ResourceRef{APIVersion: d.APIVersion, Kind: d.Kind, Name: d.Name, Namespace: d.Namespace}
+		tags := map[string]string{
+			"chart":  job.chart.Name,
+			"spec":   job.kubeObject.GetKind() + "/" + job.kubeObject.GetName(),
+			"change": d.Change,
+		}
+		object := d.Kind + " " + d.Name
+		if d.Namespace != "" {
+			object = d.Kind + " " + d.Namespace + "/" + d.Name
+		}
+		if d.Change != helm.ChangeChanged {
+			results = append(results, &fn.Result{Message: prefix + object + " " + d.Change, Severity: fn.Info, ResourceRef: ref, Tags: tags})
+			continue
+		}
+		for _, f := range d.Fields {
+			results = append(results, &fn.Result{
+				Message:     fmt.Sprintf("%v%v field %v %v", prefix, object, f.Path, f.Change),
+				Severity:    fn.Info,
+				ResourceRef: ref,
+				Field:       &fn.Field{Path: f.Path, CurrentValue: f.From, ProposedValue: f.To},
+				Tags:        tags,
+			})
+		}
+	}
+	return results
+}
@@ -0,0 +1,92 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/krm-functions/catalog/pkg/helm"
+	t "github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderDiffResults(tt *testing.T) {
+	job := testChartJob(tt, &t.HelmChartArgs{Name: "app", Version: "1.2.3"})
+	tags := func(change string) map[string]string {
+		return map[string]string{"chart": "app", "spec": "RenderHelmChart/charts", "change": change}
+	}
+	tests := []struct {
+		name     string
+		diff     []helm.ObjectDiff
+		expected fn.Results
+	}{
+		{
+			name: "added",
+			diff: []helm.ObjectDiff{{APIVersion: "v1", Kind: "ConfigMap", Name: "cfg", Change: helm.ChangeAdded}},
+			expected: fn.Results{{
+				Message:     "chart app 1.2.3 -> 1.3.0: ConfigMap cfg added",
+				Severity:    fn.Info,
+				ResourceRef: &fn.ResourceRef{APIVersion: "v1", Kind: "ConfigMap", Name: "cfg"},
+				Tags:        tags(helm.ChangeAdded),
+			}},
+		},
+		{
+			name: "removed",
+			diff: []helm.ObjectDiff{{APIVersion: "v1", Kind: "Service", Namespace: "ns", Name: "svc", Change: helm.ChangeRemoved}},
+			expected: fn.Results{{
+				Message:     "chart app 1.2.3 -> 1.3.0: Service ns/svc removed",
+				Severity:    fn.Info,
+				ResourceRef: &fn.ResourceRef{APIVersion: "v1", Kind: "Service", Namespace: "ns", Name: "svc"},
+				Tags:        tags(helm.ChangeRemoved),
+			}},
+		},
+		{
+			name: "changed",
+			diff: []helm.ObjectDiff{{
+				APIVersion: "apps/v1", Kind: "Deployment", Namespace: "ns", Name: "app", Change: helm.ChangeChanged,
+				Fields: []helm.FieldDiff{
+					{Path: "spec.template.spec.containers[0].image", Change: helm.ChangeChanged, From: "app:v2.0", To: "app:v2.1"},
+					{Path: "spec.replicas", Change: helm.ChangeAdded, To: 2},
+				},
+			}},
+			expected: fn.Results{
+				{
+					Message:     "chart app 1.2.3 -> 1.3.0: Deployment ns/app field spec.template.spec.containers[0].image changed",
+					Severity:    fn.Info,
+					ResourceRef: &fn.ResourceRef{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "ns", Name: "app"},
+					Field:       &fn.Field{Path: "spec.template.spec.containers[0].image", CurrentValue: "app:v2.0", ProposedValue: "app:v2.1"},
+					Tags:        tags(helm.ChangeChanged),
+				},
+				{
+					Message:     "chart app 1.2.3 -> 1.3.0: Deployment ns/app field spec.replicas added",
+					Severity:    fn.Info,
+					ResourceRef: &fn.ResourceRef{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "ns", Name: "app"},
+					Field:       &fn.Field{Path: "spec.replicas", ProposedValue: 2},
+					Tags:        tags(helm.ChangeChanged),
+				},
+			},
+		},
+		{
+			name: "unchanged",
+		},
+	}
+	for _, tc := range tests {
+		tt.Run(tc.name, func(tt *testing.T) {
+			ev := &chartEvaluation{newVersion: &helm.RepoSearch{Version: "1.3.0"}, renderDiff: tc.diff}
+			assert.Equal(tt, tc.expected, renderDiffResults(job, ev))
+		})
+	}
+}
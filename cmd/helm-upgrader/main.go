@@ -17,9 +17,11 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/krm-functions/catalog/pkg/api"
 	"github.com/krm-functions/catalog/pkg/helm"
@@ -30,7 +32,12 @@ import (
 	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
 )
 
-var upgradesEvaluated, upgradesDone, upgradesAvailable int
+// upgradeStats are the upgrade counters of a function run
+type upgradeStats struct {
+	Evaluated int
+	Done      int
+	Available int
+}
 
 type ChartInfo struct {
 	t.HelmChartArgs
@@ -45,20 +52,45 @@ type UpgradeInfo struct {
 	Constraint string    `json:"constraint" yaml:"constraint"`
 }
 
-// evaluateChartVersion looks up versions and find a possible upgrade that fulfills upgradeConstraint
+// chartJob is a chart to be evaluated for upgrade
+type chartJob struct {
+	chart             *t.HelmChartArgs
+	kubeObject        *fn.KubeObject
+	idx               int // Index in 'helmCharts' or -1 for Argo CD applications and Flux releases
+	versionObject     *fn.KubeObject
+	versionPath       []string // Field of versionObject holding the chart version, for charts not in 'helmCharts'
+	upgradeConstraint string
+	policy            semver.Policy
+	annotateSum       bool // Annotate sum of current chart
+	keyring           []byte
+	uname, pword      string
+	render            *renderSpec    // Only with renderDiff
+	values            []inlineValues // Inline values, only with valuesCheck
+}
+
+// chartEvaluation is the outcome of the network access needed to upgrade a chart
+type chartEvaluation struct {
+	currSearch, newVersion *helm.RepoSearch
+	currChartSum           string
+	newChartSum            string
+	valuesDiff             string            // Diff of default values, only for reports
+	renderDiff             []helm.ObjectDiff // Only with renderDiff
+	removedValues          []removedValue    // Inline values not known by the new version, only with valuesCheck
+}
+
+// evaluateChartVersion looks up versions and find a possible upgrade allowed by the upgrade policy
 // Returns repo-search for both existing and new chart
-func evaluateChartVersion(chart *t.HelmChartArgs, upgradeConstraint, username, password string) (currChartRepoSearch, newChartRepoSearch *helm.RepoSearch, err error) {
-	upgradesEvaluated++
-	if upgradeConstraint == "" {
-		upgradeConstraint = "*"
-	}
+func evaluateChartVersion(chart *t.HelmChartArgs, policy *semver.Policy, username, password string) (currChartRepoSearch, newChartRepoSearch *helm.RepoSearch, err error) {
 	search, err := helm.SearchRepo(chart, username, password)
 	if err != nil {
 		return nil, nil, err
 	}
 	search = helm.FilterByChartName(search, chart)
-	versions := helm.ToList(search)
-	newVersion, err := semver.Upgrade(versions, upgradeConstraint)
+	releases := make([]semver.Release, len(search))
+	for idx := range search {
+		releases[idx] = semver.Release{Version: search[idx].Version, Created: search[idx].Created}
+	}
+	newVersion, err := policy.Upgrade(chart.Version, releases, time.Now())
 	if err != nil {
 		return nil, nil, fmt.Errorf("chart=%v: %w", chart.Name, err)
 	}
@@ -74,24 +106,88 @@ func evaluateChartVersion(chart *t.HelmChartArgs, upgradeConstraint, username, p
 	return currChartRepoSearch, newChartRepoSearch, nil
 }
 
-// handleNewVersion applies new version to chart spec according to upgradeConstraint
-func handleNewVersion(currSearch, newVersion *helm.RepoSearch, curr *t.HelmChartArgs, kubeObject *fn.KubeObject, idx int, upgradeConstraint, uname, pword string) (*t.HelmChartArgs, string, error) {
-	upgraded := *curr
-	var chartSum string
-	infoS := UpgradeInfo{}
-
-	tmpDir, err := os.MkdirTemp("", "chart-")
+// pullChart retrieves a chart and returns it together with its
+// sha256sum. If a keyring is given, the chart provenance is verified
+func pullChart(chart *t.HelmChartArgs, keyring []byte, uname, pword string) (chartData []byte, chartSum string, err error) {
+	chartData, _, chartSum, err = helm.SourceChart(chart, "", uname, pword)
 	if err != nil {
 		return nil, "", err
 	}
-	defer os.RemoveAll(tmpDir)
+	if keyring != nil {
+		if _, err = helm.VerifyProvenance(chart, chartData, keyring, uname, pword); err != nil {
+			return nil, "", err
+		}
+	}
+	return chartData, chartSum, nil
+}
 
-	newChart := *curr
-	newChart.Version = newVersion.Version
+// evaluateChart looks up versions and pulls the charts needed for
+// upgrading a chart. It does not modify the job and is safe for
+// concurrent use
+func evaluateChart(job *chartJob) (*chartEvaluation, error) {
+	var err error
+	ev := &chartEvaluation{}
+	ev.currSearch, ev.newVersion, err = evaluateChartVersion(job.chart, &job.policy, job.uname, job.pword)
+	if err != nil {
+		return nil, err
+	}
+	if ev.newVersion.Version != job.chart.Version {
+		pullBoth := Config.Report != "" || job.render != nil || Config.ValuesCheck != ""
+		if pullBoth || Config.AnnotateSumOnUpgradeAvailable || (Config.UpgradeOnUpgradeAvailable && job.keyring != nil) {
+			// With a keyring, the new version is verified before upgrading
+			newChart := *job.chart
+			newChart.Version = ev.newVersion.Version
+			var newData, currData []byte
+			newData, ev.newChartSum, err = pullChart(&newChart, job.keyring, job.uname, job.pword)
+			if err != nil {
+				return nil, err
+			}
+			if pullBoth {
+				currData, ev.currChartSum, err = pullChart(job.chart, nil, job.uname, job.pword)
+				if err != nil {
+					return nil, err
+				}
+			}
+			if Config.Report != "" {
+				ev.valuesDiff, err = helm.DefaultValuesDiff(currData, newData,
+					job.chart.Name+"-"+job.chart.Version, newChart.Name+"-"+newChart.Version)
+				if err != nil {
+					return nil, err
+				}
+			}
+			if Config.ValuesCheck != "" {
+				ev.removedValues, err = removedValues(currData, newData, job.values)
+				if err != nil {
+					return nil, fmt.Errorf("chart=%v: %w", job.chart.Name, err)
+				}
+			}
+			if job.render != nil {
+				ev.renderDiff, err = job.render.diff(currData, newData, ev.newVersion.Version)
+				if err != nil {
+					return nil, fmt.Errorf("chart=%v: %w", job.chart.Name, err)
+				}
+			}
+		}
+	} else if job.annotateSum {
+		_, ev.currChartSum, err = pullChart(job.chart, job.keyring, job.uname, job.pword)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ev, nil
+}
 
-	if newChart.Version != curr.Version {
-		upgradesAvailable++
-		anno := curr.Repo + "/" + curr.Name + ":" + newChart.Version
+// handleNewVersion applies new version to chart spec according to upgradeConstraint
+func handleNewVersion(job *chartJob, ev *chartEvaluation, stats *upgradeStats) (*t.HelmChartArgs, string, error) {
+	curr, kubeObject, idx := job.chart, job.kubeObject, job.idx
+	upgraded := *curr
+	infoS := UpgradeInfo{}
+	var err error
+
+	stats.Evaluated++
+	if ev.newVersion.Version != curr.Version {
+		stats.Available++
+		anno := curr.Repo + "/" + curr.Name + ":" + ev.newVersion.Version
 		if Config.AnnotateOnUpgradeAvailable {
 			if idx >= 0 {
 				err = kubeObject.SetAnnotation(api.HelmResourceAnnotationUpgradeAvailable+"."+strconv.FormatInt(int64(idx), 10), anno)
@@ -105,37 +201,30 @@ func handleNewVersion(currSearch, newVersion *helm.RepoSearch, curr *t.HelmChart
 				}
 			}
 		}
-		if Config.UpgradeOnUpgradeAvailable {
-			upgradesDone++
-			upgraded.Version = newChart.Version
+		if Config.UpgradeOnUpgradeAvailable && !valuesBlocked(ev) {
+			stats.Done++
+			upgraded.Version = ev.newVersion.Version
 		}
 		if Config.AnnotateSumOnUpgradeAvailable {
-			_, chartSum, err = helm.PullChart(&newChart, tmpDir, uname, pword)
-			if err != nil {
-				return nil, "", err
-			}
 			if idx >= 0 {
-				err = kubeObject.SetAnnotation(api.HelmResourceAnnotationUpgradeShaSum+"."+strconv.FormatInt(int64(idx), 10), formatShaSum(chartSum))
+				err = kubeObject.SetAnnotation(api.HelmResourceAnnotationUpgradeShaSum+"."+strconv.FormatInt(int64(idx), 10), formatShaSum(ev.newChartSum))
 				if err != nil {
 					return nil, "", err
 				}
 			} else {
-				err = kubeObject.SetAnnotation(api.HelmResourceAnnotationUpgradeShaSum, formatShaSum(chartSum))
+				err = kubeObject.SetAnnotation(api.HelmResourceAnnotationUpgradeShaSum, formatShaSum(ev.newChartSum))
 				if err != nil {
 					return nil, "", err
 				}
 			}
-			infoS.Upgraded.ChartSum = formatShaSum(chartSum)
+			infoS.Upgraded.ChartSum = formatShaSum(ev.newChartSum)
 		}
 		infoS.Upgraded.HelmChartArgs = upgraded
 		infoS.Upgraded.Auth = nil
-		infoS.Upgraded.AppVersion = newVersion.AppVersion
-	} else if Config.AnnotateCurrentSum && kubeObject.GetAnnotation(api.HelmResourceAnnotationShaSum) == "" {
-		_, chartSum, err = helm.PullChart(curr, tmpDir, uname, pword)
-		if err != nil {
-			return nil, "", err
-		}
-		err = kubeObject.SetAnnotation(api.HelmResourceAnnotationShaSum, formatShaSum(chartSum))
+		infoS.Upgraded.AppVersion = ev.newVersion.AppVersion
+	} else if job.annotateSum && kubeObject.GetAnnotation(api.HelmResourceAnnotationShaSum) == "" {
+		// Checked again since evaluations are applied in input order, i.e. the first chart of a spec is annotated
+		err = kubeObject.SetAnnotation(api.HelmResourceAnnotationShaSum, formatShaSum(ev.currChartSum))
 		if err != nil {
 			return nil, "", err
 		}
@@ -144,9 +233,9 @@ func handleNewVersion(currSearch, newVersion *helm.RepoSearch, curr *t.HelmChart
 	// Common data, irrespective of upgrade or not...
 	infoS.Current.HelmChartArgs = *curr
 	infoS.Current.Auth = nil
-	infoS.Current.AppVersion = currSearch.AppVersion
-	infoS.Constraint = upgradeConstraint
-	distance, err := semver.Diff(curr.Version, upgraded.Version)
+	infoS.Current.AppVersion = ev.currSearch.AppVersion
+	infoS.Constraint = job.upgradeConstraint
+	distance, err := semver.DiffScheme(job.policy.VersionScheme(), curr.Version, upgraded.Version)
 	if err != nil {
 		return nil, "", err
 	}
@@ -164,11 +253,16 @@ func handleNewVersion(currSearch, newVersion *helm.RepoSearch, curr *t.HelmChart
 	return &upgraded, info, nil
 }
 
-func Run(rl *fn.ResourceList) (bool, error) {
-	cfg := rl.FunctionConfig
-	parseConfig(cfg)
-	results := &rl.Results
+// kptChartSpec is a parsed RenderHelmChart resource, whose charts are written back after upgrading
+type kptChartSpec struct {
+	kubeObject *fn.KubeObject
+	spec       *t.RenderHelmChart
+}
 
+// collectJobs looks up the charts to evaluate for upgrade together with their credentials
+func collectJobs(rl *fn.ResourceList) ([]*chartJob, []kptChartSpec, error) {
+	var jobs []*chartJob
+	var specs []kptChartSpec
 	for _, kubeObject := range rl.Items {
 		if kubeObject.IsGVK("fn.kpt.dev", "", "RenderHelmChart") || kubeObject.IsGVK(api.HelmResourceAPI, "", "RenderHelmChart") {
 			upgradeConstraint := kubeObject.GetAnnotation(api.HelmResourceAnnotationUpgradeConstraint)
@@ -176,64 +270,221 @@ func Run(rl *fn.ResourceList) (bool, error) {
 			y := kubeObject.String()
 			spec, err := t.ParseKptSpec([]byte(y))
 			if err != nil {
-				return false, err
+				return nil, nil, err
 			}
+			specs = append(specs, kptChartSpec{kubeObject, spec})
 			for idx := range spec.Charts {
 				helmChart := &spec.Charts[idx]
-				var upgraded *t.HelmChartArgs
-				var currSearch, newVersion *helm.RepoSearch
-				var info string
-				var uname, pword string
+				job := &chartJob{
+					chart:             &helmChart.Args,
+					kubeObject:        kubeObject,
+					idx:               idx,
+					upgradeConstraint: upgradeConstraint,
+					annotateSum:       Config.AnnotateCurrentSum && kubeObject.GetAnnotation(api.HelmResourceAnnotationShaSum) == "",
+				}
 				if helmChart.Args.Auth != nil {
-					uname, pword, err = util.LookupAuthSecret(helmChart.Args.Auth.Name, helmChart.Args.Auth.Namespace, rl)
+					job.uname, job.pword, err = util.LookupAuthSecret(helmChart.Args.Auth.Name, helmChart.Args.Auth.Namespace, rl)
 					if err != nil {
-						return false, err
+						return nil, nil, err
 					}
 				}
-				currSearch, newVersion, err = evaluateChartVersion(&helmChart.Args, upgradeConstraint, uname, pword)
-				if err != nil {
-					return false, err
+				if helmChart.Args.Keyring != nil {
+					job.keyring, err = util.LookupKeyringSecret(helmChart.Args.Keyring.Name, helmChart.Args.Keyring.Namespace, rl)
+					if err != nil {
+						return nil, nil, err
+					}
 				}
-				upgraded, info, err = handleNewVersion(currSearch, newVersion, &helmChart.Args, kubeObject, idx, upgradeConstraint, uname, pword)
-				if err != nil {
-					return false, err
+				job.values = []inlineValues{{
+					values: helmChart.Options.Values.ValuesInline,
+					path:   fmt.Sprintf("helmCharts[%d].templateOptions.values.valuesInline", idx),
+				}}
+				if Config.RenderDiff {
+					job.render, err = newRenderSpec(helmChart, kubeObject, rl.Items)
+					if err != nil {
+						return nil, nil, err
+					}
 				}
-				helmChart.Args.Version = upgraded.Version
-				*results = append(*results, fn.ConfigObjectResult(info, kubeObject, fn.Info))
-			}
-			err = kubeObject.SetNestedField(spec.Charts, "helmCharts")
-			if err != nil {
-				return false, err
+				jobs = append(jobs, job)
 			}
 		} else if kubeObject.IsGVK("argoproj.io", "", "Application") {
-			upgradeConstraint := kubeObject.GetAnnotation(api.HelmResourceAnnotationUpgradeConstraint)
-
 			y := kubeObject.String()
 			app, err := t.ParseArgoCDSpec([]byte(y))
 			if err != nil {
-				return false, err
+				return nil, nil, err
 			}
 			if !app.IsHelmSpec() {
 				continue
 			}
 			chartArgs := app.Spec.Source.ToKptSpec()
-			currSearch, newVersion, err := evaluateChartVersion(&chartArgs, upgradeConstraint, "", "") // FIXME private repo not supported with Argo apps
+			job := &chartJob{
+				chart:             &chartArgs,
+				kubeObject:        kubeObject,
+				idx:               -1,
+				versionObject:     kubeObject,
+				versionPath:       []string{"spec", "source", "targetRevision"},
+				upgradeConstraint: kubeObject.GetAnnotation(api.HelmResourceAnnotationUpgradeConstraint),
+				annotateSum:       Config.AnnotateCurrentSum && kubeObject.GetAnnotation(api.HelmResourceAnnotationShaSum) == "",
+			}
+			job.uname, job.pword, err = util.LookupArgoCDAuth(kubeObject, chartArgs.Repo, rl)
 			if err != nil {
-				return false, err
+				return nil, nil, err
+			}
+			if Config.RenderDiff || Config.ValuesCheck != "" {
+				chart, cErr := app.ToHelmChart(&app.Spec.Source)
+				if cErr != nil {
+					return nil, nil, fmt.Errorf("invalid application %s: %w", kubeObject.GetName(), cErr)
+				}
+				job.values, err = argoCDValues(app)
+				if err != nil {
+					return nil, nil, fmt.Errorf("invalid application %s: %w", kubeObject.GetName(), err)
+				}
+				if Config.RenderDiff {
+					job.render, err = newRenderSpec(chart, kubeObject, rl.Items)
+					if err != nil {
+						return nil, nil, err
+					}
+				}
+			}
+			jobs = append(jobs, job)
+		} else if kubeObject.IsGVK(helm.FluxHelmAPI, "", "HelmRelease") {
+			y := kubeObject.String()
+			release, err := t.ParseFluxHelmRelease([]byte(y))
+			if err != nil {
+				return nil, nil, err
+			}
+			if !release.IsHelmSource() {
+				continue
 			}
-			upgraded, info, err := handleNewVersion(currSearch, newVersion, &chartArgs, kubeObject, -1, upgradeConstraint, "", "")
+			repo, repoObject, err := helm.LookupFluxRepository(release, rl.Items)
+			if err != nil {
+				return nil, nil, err
+			}
+			chartArgs, err := release.ToKptSpec(repo)
+			if err != nil {
+				return nil, nil, err
+			}
+			job := &chartJob{
+				chart:             &chartArgs,
+				kubeObject:        kubeObject,
+				idx:               -1,
+				versionObject:     kubeObject,
+				versionPath:       []string{"spec", "chart", "spec", "version"},
+				upgradeConstraint: kubeObject.GetAnnotation(api.HelmResourceAnnotationUpgradeConstraint),
+				annotateSum:       Config.AnnotateCurrentSum && kubeObject.GetAnnotation(api.HelmResourceAnnotationShaSum) == "",
+			}
+			// Charts of OCI repositories are versioned by the repository tag
+			if repo.Kind == "OCIRepository" {
+				job.versionObject = repoObject
+				job.versionPath = []string{"spec", "ref", "tag"}
+			}
+			if chartArgs.Auth != nil {
+				job.uname, job.pword, err = util.LookupAuthSecret(chartArgs.Auth.Name, chartArgs.Auth.Namespace, rl)
+				if err != nil {
+					return nil, nil, err
+				}
+			}
+			job.values = []inlineValues{{values: release.Spec.Values, path: "spec.values"}}
+			if Config.RenderDiff {
+				values, vErr := helm.FluxValues(release, rl.Items)
+				if vErr != nil {
+					return nil, nil, vErr
+				}
+				job.render, err = newRenderSpec(release.ToHelmChart(chartArgs, values), kubeObject, rl.Items)
+				if err != nil {
+					return nil, nil, err
+				}
+			}
+			jobs = append(jobs, job)
+		}
+	}
+	for _, job := range jobs {
+		policy, err := chartPolicy(job.kubeObject, job.upgradeConstraint)
+		if err != nil {
+			return nil, nil, err
+		}
+		job.policy = policy
+	}
+	return jobs, specs, nil
+}
+
+func Run(rl *fn.ResourceList) (bool, error) {
+	cfg := rl.FunctionConfig
+	if err := parseConfig(cfg); err != nil {
+		return false, err
+	}
+	results := &rl.Results
+	stats := upgradeStats{}
+
+	jobs, specs, err := collectJobs(rl)
+	if err != nil {
+		return false, err
+	}
+
+	// Network access is done concurrently, while resources are updated sequentially in input order
+	evaluations, errs := util.ParallelMap(jobs, Config.Workers, evaluateChart)
+	var failed []error
+	for idx := range errs {
+		if errors.Is(errs[idx], helm.ErrVerificationFailed) {
+			*results = append(*results, fn.ConfigObjectResult(fmt.Sprintf("chart %v: %v", jobs[idx].chart.Name, errs[idx]), jobs[idx].kubeObject, fn.Error))
+		} else if errs[idx] != nil {
+			failed = append(failed, errs[idx])
+		}
+	}
+	if len(failed) > 0 {
+		return false, errors.Join(failed...)
+	}
+	if results.ExitCode() != 0 {
+		return false, nil
+	}
+
+	var report []ReportEntry
+	for idx, job := range jobs {
+		var upgraded *t.HelmChartArgs
+		var info string
+		upgraded, info, err = handleNewVersion(job, evaluations[idx], &stats)
+		if err != nil {
+			return false, err
+		}
+		if Config.Report != "" && evaluations[idx].newVersion.Version != job.chart.Version {
+			var entry *ReportEntry
+			entry, err = reportEntry(job, evaluations[idx], upgraded)
 			if err != nil {
 				return false, err
 			}
-			*results = append(*results, fn.ConfigObjectResult(info, kubeObject, fn.Info))
-			err = kubeObject.SetNestedField(upgraded.Version, "spec", "source", "targetRevision")
+			report = append(report, *entry)
+		}
+		*results = append(*results, fn.ConfigObjectResult(info, job.kubeObject, fn.Info))
+		*results = append(*results, renderDiffResults(job, evaluations[idx])...)
+		*results = append(*results, removedValuesResults(job, evaluations[idx])...)
+		if job.idx >= 0 {
+			job.chart.Version = upgraded.Version
+		} else {
+			err = job.versionObject.SetNestedField(upgraded.Version, job.versionPath...)
 			if err != nil {
 				return false, err
 			}
 		}
 	}
+	for _, s := range specs {
+		err = s.kubeObject.SetNestedField(s.spec.Charts, "helmCharts")
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if Config.Report != "" {
+		var reportObject *fn.KubeObject
+		reportObject, err = reportConfigMap(report, Config.Report, Config.ReportPath)
+		if err != nil {
+			return false, err
+		}
+		err = rl.UpsertObjectToItems(reportObject, nil, true)
+		if err != nil {
+			return false, err
+		}
+	}
 
-	*results = append(*results, fn.GeneralResult(fmt.Sprintf("{\"upgradesEvaluated\": %d, \"upgradesDone\": %d, \"upgradesAvailable\": %d, \"upgradesSkipped\": %d}\n", upgradesEvaluated, upgradesDone, upgradesAvailable, upgradesAvailable-upgradesDone), fn.Info))
+	*results = append(*results, fn.GeneralResult(fmt.Sprintf("{\"upgradesEvaluated\": %d, \"upgradesDone\": %d, \"upgradesAvailable\": %d, \"upgradesSkipped\": %d}\n", stats.Evaluated, stats.Done, stats.Available, stats.Available-stats.Done), fn.Info))
 	return true, nil
 }
 
@@ -0,0 +1,154 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	t "github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/krm-functions/catalog/pkg/semver"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+)
+
+// Legal report formats
+const (
+	ReportMarkdown = "markdown"
+	ReportJSON     = "json"
+)
+
+const (
+	reportName        = "helm-upgrade-report"
+	defaultReportPath = "helm-upgrade-report.yaml"
+	localConfigAnno   = "config.kubernetes.io/local-config"
+)
+
+// ReportEntry is a chart with an upgrade available in the upgrade report
+type ReportEntry struct {
+	Resource          string `json:"resource"`
+	Chart             string `json:"chart"`
+	Repo              string `json:"repo"`
+	CurrentVersion    string `json:"currentVersion"`
+	NewVersion        string `json:"newVersion"`
+	CurrentAppVersion string `json:"currentAppVersion,omitempty"`
+	NewAppVersion     string `json:"newAppVersion,omitempty"`
+	Distance          string `json:"distance,omitempty"`
+	CurrentChartSum   string `json:"currentChartSum,omitempty"`
+	NewChartSum       string `json:"newChartSum,omitempty"`
+	Upgraded          bool   `json:"upgraded"` // False if the upgrade is only available, e.g. when annotating instead of upgrading
+	ValuesDiff        string `json:"valuesDiff,omitempty"`
+}
+
+// reportEntry creates the report entry of a chart with an upgrade available
+func reportEntry(job *chartJob, ev *chartEvaluation, upgraded *t.HelmChartArgs) (*ReportEntry, error) {
+	distance, err := semver.DiffScheme(job.policy.VersionScheme(), job.chart.Version, ev.newVersion.Version)
+	if err != nil {
+		return nil, err
+	}
+	return &ReportEntry{
+		Resource:          job.kubeObject.GetKind() + "/" + job.kubeObject.GetName(),
+		Chart:             job.chart.Name,
+		Repo:              job.chart.Repo,
+		CurrentVersion:    job.chart.Version,
+		NewVersion:        ev.newVersion.Version,
+		CurrentAppVersion: ev.currSearch.AppVersion,
+		NewAppVersion:     ev.newVersion.AppVersion,
+		Distance:          distance,
+		CurrentChartSum:   formatShaSum(ev.currChartSum),
+		NewChartSum:       formatShaSum(ev.newChartSum),
+		Upgraded:          upgraded.Version == ev.newVersion.Version,
+		ValuesDiff:        ev.valuesDiff,
+	}, nil
+}
+
+// reportConfigMap returns a local-config ConfigMap with the upgrade
+// report in the given format, written to reportPath in the package
+func reportConfigMap(entries []ReportEntry, format, reportPath string) (*fn.KubeObject, error) {
+	key, report := "report.md", markdownReport(entries)
+	if format == ReportJSON {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if entries == nil {
+			entries = []ReportEntry{}
+		}
+		if err := enc.Encode(map[string]any{"upgrades": entries}); err != nil {
+			return nil, err
+		}
+		key, report = "report.json", buf.String()
+	}
+	cm := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: reportName,
+			Annotations: map[string]string{
+				localConfigAnno:               "true",
+				kioutil.PathAnnotation:        reportPath,
+				kioutil.LegacyPathAnnotation:  reportPath,
+				kioutil.IndexAnnotation:       "0",
+				kioutil.LegacyIndexAnnotation: "0",
+			},
+		},
+		Data: map[string]string{key: report},
+	}
+	return fn.NewFromTypedObject(cm)
+}
+
+// markdownReport formats the upgrade report for pasting into merge requests
+func markdownReport(entries []ReportEntry) string {
+	var b strings.Builder
+	b.WriteString("# Helm Chart Upgrades\n\n")
+	if len(entries) == 0 {
+		b.WriteString("No chart upgrades available.\n")
+		return b.String()
+	}
+	b.WriteString("| Resource | Chart | Version | App Version | Distance | Status |\n")
+	b.WriteString("|----------|-------|---------|-------------|----------|--------|\n")
+	for _, e := range entries {
+		status := "available"
+		if e.Upgraded {
+			status = "upgraded"
+		}
+		fmt.Fprintf(&b, "| %v | %v | %v → %v | %v | %v | %v |\n", e.Resource, e.Chart, e.CurrentVersion, e.NewVersion,
+			versionChange(e.CurrentAppVersion, e.NewAppVersion), e.Distance, status)
+	}
+	for _, e := range entries {
+		fmt.Fprintf(&b, "\n## %v: %v %v → %v\n\n", e.Resource, e.Chart, e.CurrentVersion, e.NewVersion)
+		fmt.Fprintf(&b, "- Repository: %v\n", e.Repo)
+		if e.CurrentAppVersion != "" || e.NewAppVersion != "" {
+			fmt.Fprintf(&b, "- App version: %v\n", versionChange(e.CurrentAppVersion, e.NewAppVersion))
+		}
+		fmt.Fprintf(&b, "- Chart sum: `%v` → `%v`\n\n", e.CurrentChartSum, e.NewChartSum)
+		if e.ValuesDiff == "" {
+			b.WriteString("No changes to default values.\n")
+		} else {
+			fmt.Fprintf(&b, "```diff\n%v```\n", e.ValuesDiff)
+		}
+	}
+	return b.String()
+}
+
+func versionChange(from, to string) string {
+	if from == to {
+		return from
+	}
+	return from + " → " + to
+}
@@ -0,0 +1,135 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/krm-functions/catalog/pkg/helm"
+	t "github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/stretchr/testify/assert"
+)
+
+func testChartJob(tt *testing.T, chart *t.HelmChartArgs) *chartJob {
+	tt.Helper()
+	kubeObject, err := fn.ParseKubeObject([]byte("apiVersion: experimental.helm.sh/v1alpha1\nkind: RenderHelmChart\nmetadata:\n  name: charts\n"))
+	if err != nil {
+		tt.Fatal(err)
+	}
+	return &chartJob{chart: chart, kubeObject: kubeObject}
+}
+
+func TestReportEntry(tt *testing.T) {
+	job := testChartJob(tt, &t.HelmChartArgs{Name: "app", Version: "1.2.3", Repo: "https://charts.example.com"})
+	ev := &chartEvaluation{
+		currSearch:   &helm.RepoSearch{Version: "1.2.3", AppVersion: "v2.0"},
+		newVersion:   &helm.RepoSearch{Version: "1.3.0", AppVersion: "v2.1"},
+		currChartSum: "aaa",
+		newChartSum:  "bbb",
+	}
+	tests := []struct {
+		name     string
+		upgraded string
+		expected bool
+	}{
+		{name: "upgraded", upgraded: "1.3.0", expected: true},
+		{name: "available", upgraded: "1.2.3", expected: false},
+	}
+	for _, tc := range tests {
+		tt.Run(tc.name, func(tt *testing.T) {
+			entry, err := reportEntry(job, ev, &t.HelmChartArgs{Name: "app", Version: tc.upgraded})
+			assert.NoError(tt, err)
+			assert.Equal(tt, &ReportEntry{
+				Resource:          "RenderHelmChart/charts",
+				Chart:             "app",
+				Repo:              "https://charts.example.com",
+				CurrentVersion:    "1.2.3",
+				NewVersion:        "1.3.0",
+				CurrentAppVersion: "v2.0",
+				NewAppVersion:     "v2.1",
+				Distance:          "0.1.0",
+				CurrentChartSum:   "sha256:aaa",
+				NewChartSum:       "sha256:bbb",
+				Upgraded:          tc.expected,
+			}, entry)
+		})
+	}
+}
+
+func TestReportConfigMap(tt *testing.T) {
+	entries := []ReportEntry{{
+		Resource:          "RenderHelmChart/charts",
+		Chart:             "app",
+		Repo:              "https://charts.example.com",
+		CurrentVersion:    "1.2.3",
+		NewVersion:        "1.3.0",
+		CurrentAppVersion: "v2.0",
+		NewAppVersion:     "v2.0",
+		Distance:          "0.1.0",
+		CurrentChartSum:   "sha256:aaa",
+		NewChartSum:       "sha256:bbb",
+		Upgraded:          true,
+		ValuesDiff:        "-replicas: 1\n+replicas: 2\n",
+	}}
+	tests := []struct {
+		name        string
+		entries     []ReportEntry
+		format      string
+		expectedKey string
+		expected    string
+	}{
+		{
+			name:        "markdown",
+			entries:     entries,
+			format:      ReportMarkdown,
+			expectedKey: "report.md",
+			expected: "# Helm Chart Upgrades\n\n" +
+				"| Resource | Chart | Version | App Version | Distance | Status |\n" +
+				"|----------|-------|---------|-------------|----------|--------|\n" +
+				"| RenderHelmChart/charts | app | 1.2.3 → 1.3.0 | v2.0 | 0.1.0 | upgraded |\n" +
+				"\n## RenderHelmChart/charts: app 1.2.3 → 1.3.0\n\n" +
+				"- Repository: https://charts.example.com\n" +
+				"- App version: v2.0\n" +
+				"- Chart sum: `sha256:aaa` → `sha256:bbb`\n\n" +
+				"```diff\n-replicas: 1\n+replicas: 2\n```\n",
+		},
+		{
+			name:        "markdown-empty",
+			format:      ReportMarkdown,
+			expectedKey: "report.md",
+			expected:    "# Helm Chart Upgrades\n\nNo chart upgrades available.\n",
+		},
+		{
+			name:        "json-empty",
+			format:      ReportJSON,
+			expectedKey: "report.json",
+			expected:    "{\n  \"upgrades\": []\n}\n",
+		},
+	}
+	for _, tc := range tests {
+		tt.Run(tc.name, func(tt *testing.T) {
+			cm, err := reportConfigMap(tc.entries, tc.format, "reports/upgrades.yaml")
+			assert.NoError(tt, err)
+			assert.True(tt, cm.IsGVK("v1", "", "ConfigMap"))
+			assert.Equal(tt, reportName, cm.GetName())
+			assert.Equal(tt, "true", cm.GetAnnotation(localConfigAnno))
+			assert.Equal(tt, "reports/upgrades.yaml", cm.PathAnnotation())
+			data, _, err := cm.NestedStringMap("data")
+			assert.NoError(tt, err)
+			assert.Equal(tt, map[string]string{tc.expectedKey: tc.expected}, data)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/krm-functions/catalog/pkg/helm"
+	t "github.com/krm-functions/catalog/pkg/helmspecs"
+)
+
+// inlineValues are inline values of a chart spec and the field holding them
+type inlineValues struct {
+	values map[string]any
+	path   string
+	// param is true for the values of an Argo CD parameter, i.e. fields reference the parameter and not value keys
+	param bool
+}
+
+// removedValue is an inline value not known by the new version of a chart
+type removedValue struct {
+	key   string // Key path of the value, e.g. 'image.tag'
+	field string // Field of the chart spec setting the value
+}
+
+// removedValues returns the inline values not known by the new version of a chart
+func removedValues(currChart, newChart []byte, values []inlineValues) ([]removedValue, error) {
+	var removed []removedValue
+	for _, iv := range values {
+		keys, err := helm.RemovedValues(currChart, newChart, iv.values)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range keys {
+			field := iv.path
+			if !iv.param {
+				field += "." + k
+			}
+			removed = append(removed, removedValue{key: k, field: field})
+		}
+	}
+	return removed, nil
+}
+
+// argoCDValues returns the inline values of an Argo CD application.
+// Values of parameters are returned separately from 'valuesObject'
+// or 'values', such that removed values reference the parameter
+func argoCDValues(app *t.ArgoCDHelmApp) ([]inlineValues, error) {
+	src := app.Spec.Source
+	var params []t.ArgoCDHelmParameter
+	path := "spec.source.helm.values"
+	if src.Helm != nil {
+		helmOpts := *src.Helm
+		params, helmOpts.Parameters = helmOpts.Parameters, nil
+		src.Helm = &helmOpts
+		if len(helmOpts.ValuesObject) > 0 {
+			path = "spec.source.helm.valuesObject"
+		}
+	}
+	chart, err := app.ToHelmChart(&src)
+	if err != nil {
+		return nil, err
+	}
+	values := []inlineValues{{values: chart.Options.Values.ValuesInline, path: path}}
+	for idx := range params {
+		paramValues, pErr := params[idx].Values()
+		if pErr != nil {
+			return nil, fmt.Errorf("parsing parameter %v: %w", params[idx].Name, pErr)
+		}
+		values = append(values, inlineValues{values: paramValues, path: fmt.Sprintf("spec.source.helm.parameters[%d]", idx), param: true})
+	}
+	return values, nil
+}
+
+// valuesBlocked returns true if an upgrade is blocked by inline values not known by the new version
+func valuesBlocked(ev *chartEvaluation) bool {
+	return Config.ValuesCheck == ValuesCheckBlock && len(ev.removedValues) > 0
+}
+
+// removedValuesResults returns warnings for the inline values of a
+// chart that are not known by the new version, referencing the fields
+// holding the values
+func removedValuesResults(job *chartJob, ev *chartEvaluation) fn.Results {
+	var results fn.Results
+	for _, rv := range ev.removedValues {
+		msg := fmt.Sprintf("chart %v %v -> %v: value %v not found in new chart version", job.chart.Name, job.chart.Version, ev.newVersion.Version, rv.key)
+		if valuesBlocked(ev) {
+			msg += ", upgrade blocked"
+		}
+		r := fn.ConfigObjectResult(msg, job.kubeObject, fn.Warning)
+		r.Field = &fn.Field{Path: rv.field}
+		results = append(results, r)
+	}
+	return results
+}
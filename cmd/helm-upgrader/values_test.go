@@ -0,0 +1,171 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/krm-functions/catalog/pkg/helm"
+	t "github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// testChartTarball packages a chart with the given default values
+func testChartTarball(tt *testing.T, version, values string) []byte {
+	tt.Helper()
+	dir := filepath.Join(tt.TempDir(), "app")
+	if err := os.MkdirAll(filepath.Join(dir, "templates"), 0o755); err != nil {
+		tt.Fatal(err)
+	}
+	files := map[string]string{
+		"Chart.yaml":        "apiVersion: v2\nname: app\nversion: " + version + "\n",
+		"values.yaml":       values,
+		"templates/cm.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n",
+	}
+	for file, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0o600); err != nil {
+			tt.Fatal(err)
+		}
+	}
+	chrt, err := loader.LoadDir(dir)
+	if err != nil {
+		tt.Fatal(err)
+	}
+	tarball, err := chartutil.Save(chrt, tt.TempDir())
+	if err != nil {
+		tt.Fatal(err)
+	}
+	data, err := os.ReadFile(tarball)
+	if err != nil {
+		tt.Fatal(err)
+	}
+	return data
+}
+
+func testArgoCDApp(tt *testing.T, helmOpts string) *t.ArgoCDHelmApp {
+	tt.Helper()
+	app, err := t.ParseArgoCDSpec([]byte(`apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: app
+spec:
+  source:
+    chart: app
+    repoURL: https://charts.example.com
+    targetRevision: 1.0.0
+` + helmOpts))
+	if err != nil {
+		tt.Fatal(err)
+	}
+	return app
+}
+
+func TestArgoCDValues(tt *testing.T) {
+	tests := []struct {
+		name     string
+		helmOpts string
+		expected []inlineValues
+	}{
+		{
+			name: "values",
+			helmOpts: `    helm:
+      values: |
+        replicas: 2
+`,
+			expected: []inlineValues{
+				{values: map[string]any{"replicas": 2}, path: "spec.source.helm.values"},
+			},
+		},
+		{
+			name: "parameters",
+			helmOpts: `    helm:
+      valuesObject:
+        image:
+          tag: v1
+      parameters:
+      - name: image.tag
+        value: v2
+      - name: oldKey
+        value: x
+`,
+			expected: []inlineValues{
+				{values: map[string]any{"image": map[string]any{"tag": "v1"}}, path: "spec.source.helm.valuesObject"},
+				{values: map[string]any{"image": map[string]any{"tag": "v2"}}, path: "spec.source.helm.parameters[0]", param: true},
+				{values: map[string]any{"oldKey": "x"}, path: "spec.source.helm.parameters[1]", param: true},
+			},
+		},
+	}
+	for _, tc := range tests {
+		tt.Run(tc.name, func(tt *testing.T) {
+			values, err := argoCDValues(testArgoCDApp(tt, tc.helmOpts))
+			assert.NoError(tt, err)
+			assert.Equal(tt, tc.expected, values)
+		})
+	}
+}
+
+func TestRemovedValuesResults(tt *testing.T) {
+	currChart := testChartTarball(tt, "1.0.0", "replicas: 1\noldKey: \"\"\n")
+	newChart := testChartTarball(tt, "1.1.0", "replicas: 1\n")
+	values, err := argoCDValues(testArgoCDApp(tt, `    helm:
+      valuesObject:
+        replicas: 2
+        oldKey: a
+      parameters:
+      - name: oldKey
+        value: b
+`))
+	if err != nil {
+		tt.Fatal(err)
+	}
+	removed, err := removedValues(currChart, newChart, values)
+	assert.NoError(tt, err)
+	assert.Equal(tt, []removedValue{
+		{key: "oldKey", field: "spec.source.helm.valuesObject.oldKey"},
+		{key: "oldKey", field: "spec.source.helm.parameters[0]"},
+	}, removed)
+
+	job := testChartJob(tt, &t.HelmChartArgs{Name: "app", Version: "1.0.0"})
+	ev := &chartEvaluation{newVersion: &helm.RepoSearch{Version: "1.1.0"}, removedValues: removed}
+	defer func() { Config.ValuesCheck = "" }()
+	tests := []struct {
+		valuesCheck string
+		blocked     bool
+		suffix      string
+	}{
+		{valuesCheck: ValuesCheckWarn, blocked: false, suffix: ""},
+		{valuesCheck: ValuesCheckBlock, blocked: true, suffix: ", upgrade blocked"},
+	}
+	for _, tc := range tests {
+		tt.Run(tc.valuesCheck, func(tt *testing.T) {
+			Config.ValuesCheck = tc.valuesCheck
+			assert.Equal(tt, tc.blocked, valuesBlocked(ev))
+			results := removedValuesResults(job, ev)
+			if assert.Len(tt, results, 2) {
+				for idx, r := range results {
+					assert.Equal(tt, "chart app 1.0.0 -> 1.1.0: value oldKey not found in new chart version"+tc.suffix, r.Message)
+					assert.Equal(tt, fn.Warning, r.Severity)
+					assert.Equal(tt, &fn.Field{Path: removed[idx].field}, r.Field)
+					assert.Equal(tt, "charts", r.ResourceRef.Name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+)
+
+// Actions on embedded chart sha256sum mismatch
+const (
+	ChartSumMismatchFail = "fail"
+	ChartSumMismatchWarn = "warn"
+)
+
+// Provenance annotations which can be set on rendered objects
+const (
+	AnnotateRenderedBy     = "renderedBy"
+	AnnotateChartName      = "chartName"
+	AnnotateChartVersion   = "chartVersion"
+	AnnotateChartSum       = "chartSum"
+	AnnotateTemplateSource = "templateSource"
+	AnnotateAll            = "all"
+)
+
+var allAnnotations = []string{AnnotateRenderedBy, AnnotateChartName, AnnotateChartVersion, AnnotateChartSum, AnnotateTemplateSource}
+
+type fnConfig struct {
+	ChartSumMismatch string          `json:"chartSumMismatch,omitempty" yaml:"chartSumMismatch,omitempty"`
+	Annotations      map[string]bool `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+	PathLayout       string          `json:"pathLayout,omitempty" yaml:"pathLayout,omitempty"`
+	ValidateOnly     bool            `json:"validateOnly,omitempty" yaml:"validateOnly,omitempty"`
+}
+
+var Config fnConfig
+
+func parseConfig(configmap *fn.KubeObject) error {
+	Config.ChartSumMismatch = ChartSumMismatchFail
+	Config.Annotations = map[string]bool{}
+	Config.PathLayout = ""
+	Config.ValidateOnly = false
+	if configmap == nil || !configmap.IsGVK("v1", "", "ConfigMap") {
+		return nil
+	}
+	if val, found, err := configmap.NestedString("data", "chartSumMismatch"); err == nil && found {
+		switch val {
+		case ChartSumMismatchFail, ChartSumMismatchWarn:
+			Config.ChartSumMismatch = val
+		default:
+			return fmt.Errorf("unsupported chartSumMismatch: %v", val)
+		}
+	}
+	if val, found, err := configmap.NestedString("data", "annotations"); err == nil && found {
+		for _, a := range strings.Split(val, ",") {
+			a = strings.TrimSpace(a)
+			switch {
+			case a == "":
+			case a == AnnotateAll:
+				for _, all := range allAnnotations {
+					Config.Annotations[all] = true
+				}
+			case slices.Contains(allAnnotations, a):
+				Config.Annotations[a] = true
+			default:
+				return fmt.Errorf("unsupported annotation: %v", a)
+			}
+		}
+	}
+	// ConfigMap data values are strings, e.g. "true", but YAML booleans are accepted too
+	if val, found, err := configmap.NestedString("data", "validateOnly"); err == nil && found {
+		if Config.ValidateOnly, err = strconv.ParseBool(val); err != nil {
+			return fmt.Errorf("invalid validateOnly %q: expected true or false", val)
+		}
+	} else if err != nil {
+		if Config.ValidateOnly, _, err = configmap.NestedBool("data", "validateOnly"); err != nil {
+			return fmt.Errorf("invalid validateOnly: %w", err)
+		}
+	}
+	if val, found, err := configmap.NestedString("data", "pathLayout"); err == nil && found {
+		if err = validatePathLayout(val); err != nil {
+			return err
+		}
+		Config.PathLayout = val
+	}
+	return nil
+}
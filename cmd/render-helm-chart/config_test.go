@@ -0,0 +1,56 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/stretchr/testify/assert"
+)
+
+func testConfigMap(t *testing.T, data string) *fn.KubeObject {
+	t.Helper()
+	configmap, err := fn.ParseKubeObject([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cfg\ndata:\n" + data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return configmap
+}
+
+func TestParseConfigValidateOnly(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected bool
+		invalid  bool
+	}{
+		{data: "  validateOnly: \"true\"\n", expected: true},
+		{data: "  validateOnly: \"false\"\n", expected: false},
+		{data: "  validateOnly: true\n", expected: true},
+		{data: "  other: value\n", expected: false},
+		{data: "  validateOnly: \"yes please\"\n", invalid: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.data, func(t *testing.T) {
+			err := parseConfig(testConfigMap(t, tc.data))
+			if tc.invalid {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, Config.ValidateOnly)
+		})
+	}
+}
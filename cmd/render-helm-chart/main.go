@@ -15,9 +15,12 @@
 package main
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
 
 	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
 	"github.com/krm-functions/catalog/pkg/api"
@@ -26,15 +29,100 @@ import (
 	"github.com/krm-functions/catalog/pkg/util"
 )
 
+// chartSumAnnotation returns the sha256sum recorded for a chart. Charts
+// sourced with source-helm-chart are annotated per chart name, while
+// the deprecated sourcing of render-helm-chart annotates a single chart
+func chartSumAnnotation(kubeObject *fn.KubeObject, chartName string, numCharts int) string {
+	if sum := kubeObject.GetAnnotation(api.HelmResourceAnnotationShaSum + "/" + chartName); sum != "" {
+		return sum
+	}
+	if numCharts == 1 {
+		return kubeObject.GetAnnotation(api.HelmResourceAnnotationShaSum)
+	}
+	return ""
+}
+
+// renderChart renders a chart of a RenderHelmChart resource or an Argo
+// CD application. Returns false if the chart is not rendered due to
+// failures reported in results
+func renderChart(rl *fn.ResourceList, kubeObject *fn.KubeObject, chart *t.HelmChart, numCharts int, loc valuesLocation, indexes map[string]int, results *fn.Results) (fn.KubeObjects, bool, error) {
+	chartTarball, err := base64.StdEncoding.DecodeString(chart.Chart)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(chartTarball) == 0 {
+		return nil, false, fmt.Errorf("no embedded chart found")
+	}
+	chartSum := fmt.Sprintf("sha256:%x", sha256.Sum256(chartTarball))
+	if expected := chartSumAnnotation(kubeObject, chart.Args.Name, numCharts); expected != "" {
+		if chartSum != expected {
+			msg := fmt.Sprintf("chart %v: embedded chart sum %v does not match annotation %v", chart.Args.Name, chartSum, expected)
+			if Config.ChartSumMismatch == ChartSumMismatchFail {
+				*results = append(*results, fn.ConfigObjectResult(msg, kubeObject, fn.Error))
+				return nil, false, nil
+			}
+			*results = append(*results, fn.ConfigObjectResult(msg, kubeObject, fn.Warning))
+		}
+	}
+	if err = helm.ApplyClusterProfile(chart, rl.Items); err != nil {
+		return nil, false, err
+	}
+	valuesFiles, err := helm.PackageValuesFiles(chart, kubeObject.PathAnnotation(), rl.Items)
+	if err != nil {
+		return nil, false, err
+	}
+	verrs, err := helm.ValidateValues(chart, chartTarball, valuesFiles)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, ve := range verrs {
+		*results = append(*results, valuesErrorResult(kubeObject, chart, loc, ve))
+	}
+	if len(verrs) > 0 {
+		return nil, false, nil
+	}
+	if Config.ValidateOnly {
+		*results = append(*results, fn.ConfigObjectResult(fmt.Sprintf("chart %v: values valid", chart.Args.Name), kubeObject, fn.Info))
+		return nil, true, nil
+	}
+	rendered, warnings, err := helm.Template(chart, chartTarball, valuesFiles, rl.Items)
+	var te *helm.TemplateError
+	if errors.As(err, &te) {
+		*results = append(*results, templateErrorResult(kubeObject, chart, loc, te))
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	for _, w := range warnings {
+		*results = append(*results, fn.ConfigObjectResult(fmt.Sprintf("chart %v: %v", chart.Args.Name, w), kubeObject, fn.Warning))
+	}
+	rendered, err = helm.PostRender(chart, rendered)
+	if err != nil {
+		return nil, false, err
+	}
+	newobjs, err := renderedObjects(rendered, kubeObject, chart, chartSum, indexes)
+	if err != nil {
+		return nil, false, err
+	}
+	return newobjs, true, nil
+}
+
 func Run(rl *fn.ResourceList) (bool, error) {
 	var outputs fn.KubeObjects
 	var results fn.Results
 
+	if err := parseConfig(rl.FunctionConfig); err != nil {
+		return false, err
+	}
+
 	results = append(results, &fn.Result{
 		Message:  "render-helm-chart",
 		Severity: fn.Info,
 	})
 
+	indexes := pathIndexes(rl.Items)
+	invalid := false
 	for _, kubeObject := range rl.Items {
 		switch {
 		case kubeObject.IsGVK(api.HelmResourceAPI, "", "RenderHelmChart"):
@@ -49,23 +137,95 @@ func Run(rl *fn.ResourceList) (bool, error) {
 				}
 			}
 			for idx := range spec.Charts {
-				chartTarball, err := base64.StdEncoding.DecodeString(spec.Charts[idx].Chart)
+				chart := &spec.Charts[idx]
+				loc := valuesLocation{
+					path:       fmt.Sprintf("helmCharts[%d].templateOptions.values", idx),
+					inlinePath: "valuesInline",
+					inline:     chart.Options.Values.ValuesInline,
+				}
+				newobjs, ok, err := renderChart(rl, kubeObject, chart, len(spec.Charts), loc, indexes, &results)
 				if err != nil {
 					return false, err
 				}
-				if len(chartTarball) == 0 {
-					return false, fmt.Errorf("no embedded chart found")
+				invalid = invalid || !ok
+				outputs = append(outputs, newobjs...)
+			}
+			if Config.ValidateOnly {
+				outputs = append(outputs, kubeObject)
+			}
+		// Argo CD applications are rendered if charts have been embedded by source-helm-chart
+		case kubeObject.IsGVK("argoproj.io", "", "Application"):
+			y := kubeObject.String()
+			app, err := t.ParseArgoCDSpec([]byte(y))
+			if err != nil {
+				return false, err
+			}
+			srcs := app.AllSources()
+			embedded := slices.ContainsFunc(srcs, func(src t.ArgoCDHelmSource) bool { return src.Chart != "" })
+			if !embedded || Config.ValidateOnly {
+				outputs = append(outputs, kubeObject)
+			}
+			for idx := range srcs {
+				if srcs[idx].Chart == "" {
+					continue
+				}
+				// Values files of other sources, e.g. '$values/values.yaml', are not part of the package
+				if vf := srcs[idx].RefValueFile(); vf >= 0 {
+					r := fn.ConfigObjectResult(fmt.Sprintf("chart %v: value files from other sources are not supported: %v",
+						srcs[idx].Name, srcs[idx].Helm.ValueFiles[vf]), kubeObject, fn.Error)
+					r.Field = &fn.Field{Path: fmt.Sprintf("%v.helm.valueFiles[%d]", app.SourcePath(idx), vf)}
+					results = append(results, r)
+					invalid = true
+					continue
 				}
-				rendered, err := helm.Template(&spec.Charts[idx], chartTarball)
+				chart, err := app.ToHelmChart(&srcs[idx])
 				if err != nil {
-					return false, err
+					return false, fmt.Errorf("invalid application %s: %w", kubeObject.GetName(), err)
 				}
-				newobjs, err := helm.ParseAsKubeObjects(rendered)
+				loc := valuesLocation{path: app.SourcePath(idx) + ".helm", inlinePath: "valuesObject"}
+				if srcs[idx].Helm != nil {
+					loc.inline = srcs[idx].Helm.ValuesObject
+				}
+				newobjs, ok, err := renderChart(rl, kubeObject, chart, 0, loc, indexes, &results)
 				if err != nil {
 					return false, err
 				}
+				invalid = invalid || !ok
 				outputs = append(outputs, newobjs...)
 			}
+		// Flux releases are rendered if charts have been embedded by source-helm-chart
+		case kubeObject.IsGVK(helm.FluxHelmAPI, "", "HelmRelease"):
+			y := kubeObject.String()
+			release, err := t.ParseFluxHelmRelease([]byte(y))
+			if err != nil {
+				return false, err
+			}
+			if release.Spec.EmbeddedChart == "" || Config.ValidateOnly {
+				outputs = append(outputs, kubeObject)
+			}
+			if release.Spec.EmbeddedChart == "" {
+				continue
+			}
+			repo, _, err := helm.LookupFluxRepository(release, rl.Items)
+			if err != nil {
+				return false, err
+			}
+			args, err := release.ToKptSpec(repo)
+			if err != nil {
+				return false, err
+			}
+			values, err := helm.FluxValues(release, rl.Items)
+			if err != nil {
+				return false, err
+			}
+			chart := release.ToHelmChart(args, values)
+			loc := valuesLocation{path: "spec", inlinePath: "values", inline: release.Spec.Values}
+			newobjs, ok, err := renderChart(rl, kubeObject, chart, 0, loc, indexes, &results)
+			if err != nil {
+				return false, err
+			}
+			invalid = invalid || !ok
+			outputs = append(outputs, newobjs...)
 		// Sourcing based on `fn.kpt.dev` is deprecated. Use the `source-helm-chart` function instead
 		case kubeObject.IsGVK("fn.kpt.dev", "", "RenderHelmChart"):
 			results = append(results, &fn.Result{
@@ -100,6 +260,11 @@ func Run(rl *fn.ResourceList) (bool, error) {
 				if err != nil {
 					return false, err
 				}
+				if _, err = helm.VerifyChart(&chart.Args, chartData, rl, uname, pword); err != nil {
+					results = append(results, fn.ConfigObjectResult(fmt.Sprintf("chart %v: %v", chart.Args.Name, err), kubeObject, fn.Error))
+					rl.Results = results
+					return false, nil
+				}
 				err = kubeObject.SetAPIVersion(api.HelmResourceAPIVersion)
 				if err != nil {
 					return false, err
@@ -127,6 +292,9 @@ func Run(rl *fn.ResourceList) (bool, error) {
 	}
 
 	rl.Results = results
+	if invalid {
+		return false, nil
+	}
 	rl.Items = outputs
 	return true, nil
 }
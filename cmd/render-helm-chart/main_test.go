@@ -0,0 +1,150 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// testChartTarball packages a chart with a values schema
+func testChartTarball(t *testing.T) []byte {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "schema")
+	files := map[string]string{
+		"Chart.yaml":         "apiVersion: v2\nname: schema\nversion: 0.1.0\n",
+		"values.yaml":        "replicaCount: 1\n",
+		"values.schema.json": `{"type": "object", "properties": {"replicaCount": {"type": "integer", "minimum": 1}}}`,
+		"templates/cm.yaml":  "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: schema\ndata:\n  replicas: \"{{ .Values.replicaCount }}\"\n",
+	}
+	for file, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, file)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chrt, err := loader.LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tarball, err := chartutil.Save(chrt, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+const testRenderHelmChart = `apiVersion: experimental.helm.sh/v1alpha1
+kind: RenderHelmChart
+metadata:
+  name: render
+helmCharts:
+- chartArgs:
+    name: schema
+    version: 0.1.0
+    repo: https://charts.example.com
+  templateOptions:
+    releaseName: rel
+    values:
+      valuesInline:
+        replicaCount: %d
+  chart: %s
+`
+
+func TestRunValidateOnly(t *testing.T) {
+	chart := base64.StdEncoding.EncodeToString(testChartTarball(t))
+	tests := []struct {
+		name          string
+		config        string
+		replicas      int
+		expectedOk    bool
+		expectedKinds []string
+		expected      fn.Result
+	}{
+		{
+			name:          "render",
+			config:        "  validateOnly: \"false\"\n",
+			replicas:      2,
+			expectedOk:    true,
+			expectedKinds: []string{"ConfigMap"},
+		},
+		{
+			name:          "valid",
+			config:        "  validateOnly: \"true\"\n",
+			replicas:      2,
+			expectedOk:    true,
+			expectedKinds: []string{"RenderHelmChart"},
+			expected:      fn.Result{Message: "chart schema: values valid", Severity: fn.Info},
+		},
+		{
+			name:       "invalid",
+			config:     "  validateOnly: \"true\"\n",
+			replicas:   0,
+			expectedOk: false,
+			expected: fn.Result{
+				Message:  "chart schema: values replicaCount: Must be greater than or equal to 1",
+				Severity: fn.Error,
+				Field:    &fn.Field{Path: "helmCharts[0].templateOptions.values.valuesInline.replicaCount"},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			spec, err := fn.ParseKubeObject([]byte(fmt.Sprintf(testRenderHelmChart, tc.replicas, chart)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rl := &fn.ResourceList{Items: fn.KubeObjects{spec}, FunctionConfig: testConfigMap(t, tc.config)}
+			ok, err := Run(rl)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedOk, ok)
+			if tc.expectedOk {
+				var kinds []string
+				for _, obj := range rl.Items {
+					kinds = append(kinds, obj.GetKind())
+				}
+				assert.Equal(t, tc.expectedKinds, kinds)
+			}
+			if tc.expected.Message == "" {
+				return
+			}
+			var found *fn.Result
+			for _, r := range rl.Results {
+				if r.Message == tc.expected.Message {
+					found = r
+				}
+			}
+			if assert.NotNil(t, found, "result %q not found in %v", tc.expected.Message, rl.Results) {
+				assert.Equal(t, tc.expected.Severity, found.Severity)
+				assert.Equal(t, tc.expected.Field, found.Field)
+				assert.Equal(t, "render", found.ResourceRef.Name)
+			}
+		})
+	}
+}
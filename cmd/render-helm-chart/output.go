@@ -0,0 +1,143 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/krm-functions/catalog/pkg/api"
+	"github.com/krm-functions/catalog/pkg/helm"
+	t "github.com/krm-functions/catalog/pkg/helmspecs"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+)
+
+// pathLayoutVars are the placeholders supported in path layouts
+var pathLayoutVars = []string{"release", "chart", "namespace", "kind", "name", "template"}
+
+var pathLayoutVarRegexp = regexp.MustCompile(`<([^<>]*)>`)
+
+// validatePathLayout checks that a path layout only uses known
+// placeholders and results in YAML files within the package
+func validatePathLayout(layout string) error {
+	if layout == "" {
+		return nil
+	}
+	for _, m := range pathLayoutVarRegexp.FindAllStringSubmatch(layout, -1) {
+		if !slices.Contains(pathLayoutVars, m[1]) {
+			return fmt.Errorf("unsupported pathLayout placeholder <%v>, supported: <%v>", m[1], strings.Join(pathLayoutVars, ">, <"))
+		}
+	}
+	if path.IsAbs(layout) || slices.Contains(strings.Split(layout, "/"), "..") {
+		return fmt.Errorf("pathLayout %q must be relative and within the package", layout)
+	}
+	if ext := path.Ext(layout); ext != ".yaml" && ext != ".yml" {
+		return fmt.Errorf("pathLayout %q must end with .yaml or .yml", layout)
+	}
+	return nil
+}
+
+// renderedObjects parses rendered chart manifests and sets the
+// provenance annotations and path layout enabled in the function
+// config. Paths are relative to the directory of the RenderHelmChart
+// resource. Indexes are counted per path, starting after objects
+// already in the resource list
+func renderedObjects(rendered []byte, kubeObject *fn.KubeObject, chart *t.HelmChart, chartSum string, indexes map[string]int) (fn.KubeObjects, error) {
+	if len(Config.Annotations) == 0 && Config.PathLayout == "" {
+		return helm.ParseAsKubeObjects(rendered)
+	}
+	nodes, err := helm.ParseAsRNodes(rendered)
+	if err != nil {
+		return nil, err
+	}
+	var objects fn.KubeObjects
+	for _, node := range nodes {
+		source := helm.TemplateSource(node)
+		o, parseErr := fn.ParseKubeObject([]byte(node.MustString()))
+		if parseErr != nil {
+			if strings.Contains(parseErr.Error(), "expected exactly one object, got 0") {
+				continue
+			}
+			return nil, fmt.Errorf("failed to parse %s: %s", node.MustString(), parseErr.Error())
+		}
+		annotations := map[string]string{}
+		if Config.Annotations[AnnotateRenderedBy] {
+			annotations[api.HelmResourceAnnotationRenderedBy] = kubeObject.GetName()
+		}
+		if Config.Annotations[AnnotateChartName] {
+			annotations[api.HelmResourceAnnotationChartName] = chart.Args.Name
+		}
+		if Config.Annotations[AnnotateChartVersion] {
+			annotations[api.HelmResourceAnnotationChartVersion] = chart.Args.Version
+		}
+		if Config.Annotations[AnnotateChartSum] {
+			annotations[api.HelmResourceAnnotationRenderedShaSum] = chartSum
+		}
+		if Config.Annotations[AnnotateTemplateSource] && source != "" {
+			annotations[api.HelmResourceAnnotationTemplateSource] = source
+		}
+		if Config.PathLayout != "" {
+			p := objectPath(Config.PathLayout, path.Dir(kubeObject.PathAnnotation()), chart, o, source)
+			idx := strconv.Itoa(indexes[p])
+			indexes[p]++
+			annotations[kioutil.PathAnnotation] = p
+			annotations[kioutil.LegacyPathAnnotation] = p
+			annotations[kioutil.IndexAnnotation] = idx
+			annotations[kioutil.LegacyIndexAnnotation] = idx
+		}
+		// Sorted for stable output
+		for _, k := range slices.Sorted(maps.Keys(annotations)) {
+			if err = o.SetAnnotation(k, annotations[k]); err != nil {
+				return nil, err
+			}
+		}
+		objects = append(objects, o)
+	}
+	return objects, nil
+}
+
+// objectPath expands a path layout for a rendered object
+func objectPath(layout, dir string, chart *t.HelmChart, o *fn.KubeObject, source string) string {
+	vars := map[string]string{
+		"release":   chart.Options.ReleaseName,
+		"chart":     chart.Args.Name,
+		"namespace": o.GetNamespace(),
+		"kind":      strings.ToLower(o.GetKind()),
+		"name":      o.GetName(),
+		// Template path within the chart, without the chart name prefix and extension
+		"template": strings.TrimSuffix(strings.TrimPrefix(source, chart.Args.Name+"/"), path.Ext(source)),
+	}
+	p := pathLayoutVarRegexp.ReplaceAllStringFunc(layout, func(m string) string {
+		return vars[m[1:len(m)-1]]
+	})
+	return path.Join(dir, path.Clean(p))
+}
+
+// pathIndexes returns the next free index of each path in a resource list
+func pathIndexes(items fn.KubeObjects) map[string]int {
+	indexes := map[string]int{}
+	for _, o := range items {
+		if p := o.PathAnnotation(); p != "" && o.IndexAnnotation() >= indexes[p] {
+			indexes[p] = o.IndexAnnotation() + 1
+		}
+	}
+	return indexes
+}
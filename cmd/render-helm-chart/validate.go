@@ -0,0 +1,79 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/krm-functions/catalog/pkg/helm"
+	t "github.com/krm-functions/catalog/pkg/helmspecs"
+)
+
+// valuesLocation is where the values of a chart are given within the chart spec resource
+type valuesLocation struct {
+	path       string         // Path of the values, e.g. 'helmCharts[0].templateOptions.values'
+	inlinePath string         // Path of the inline values relative to path, e.g. 'valuesInline'
+	inline     map[string]any // Inline values
+}
+
+// valuesErrorResult returns an error result for a chart value violating the chart values schema
+func valuesErrorResult(kubeObject *fn.KubeObject, chart *t.HelmChart, loc valuesLocation, ve helm.ValuesError) *fn.Result {
+	field := ve.Field
+	if field == "" {
+		field = "(root)"
+	}
+	r := fn.ConfigObjectResult(fmt.Sprintf("chart %v: values %v: %v", chart.Args.Name, field, ve.Message), kubeObject, fn.Error)
+	r.Field = &fn.Field{Path: loc.fieldPath(ve.Field)}
+	return r
+}
+
+// templateErrorResult returns an error result for a chart template
+// failing to render. The field path refers to the offending value, if
+// reported by Helm
+func templateErrorResult(kubeObject *fn.KubeObject, chart *t.HelmChart, loc valuesLocation, te *helm.TemplateError) *fn.Result {
+	r := fn.ConfigObjectResult(fmt.Sprintf("chart %v: template %v", chart.Args.Name, te.Error()), kubeObject, fn.Error)
+	if te.ValuesKey != "" {
+		r.Field = &fn.Field{Path: loc.fieldPath(te.ValuesKey)}
+	}
+	return r
+}
+
+// fieldPath returns the path of a value within the chart spec
+// resource, i.e. the longest prefix of the value key given in inline
+// values. Values not given inline, e.g. values from values files or
+// chart defaults, refer to the chart values
+func (loc *valuesLocation) fieldPath(field string) string {
+	if field == "" {
+		return loc.path
+	}
+	var found []string
+	var val any = loc.inline
+	for _, key := range strings.Split(field, ".") {
+		m, ok := val.(map[string]any)
+		if !ok {
+			break
+		}
+		if val, ok = m[key]; !ok {
+			break
+		}
+		found = append(found, key)
+	}
+	if len(found) == 0 {
+		return loc.path
+	}
+	return loc.path + "." + loc.inlinePath + "." + strings.Join(found, ".")
+}
@@ -0,0 +1,39 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/krm-functions/catalog/pkg/util"
+)
+
+type fnConfig struct {
+	Workers int `json:"workers,omitempty" yaml:"workers,omitempty"`
+}
+
+var Config fnConfig
+
+func parseConfig(configmap *fn.KubeObject) error {
+	Config.Workers = util.DefaultWorkers
+	if configmap == nil || !configmap.IsGVK("v1", "", "ConfigMap") {
+		return nil
+	}
+	workers, err := util.ConfigWorkers(configmap)
+	if err != nil {
+		return err
+	}
+	Config.Workers = workers
+	return nil
+}
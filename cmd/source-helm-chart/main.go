@@ -15,7 +15,9 @@
 package main
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"os"
 
@@ -26,9 +28,128 @@ import (
 	"github.com/krm-functions/catalog/pkg/util"
 )
 
+// sourceJob is a chart to be sourced
+type sourceJob struct {
+	kubeObject   *fn.KubeObject
+	chart        *t.HelmChart
+	idx          int // Index in 'helmCharts', or in the sources of an Argo CD application
+	uname, pword string
+}
+
+// sourcedChart is a retrieved and verified chart
+type sourcedChart struct {
+	chartData    []byte
+	chartSum     string
+	signer       string
+	cosignSigner string
+	dependencies []helm.Dependency
+}
+
+// sourceChart retrieves and verifies a chart. It does not modify the
+// resource list and is safe for concurrent use
+func sourceChart(job *sourceJob, rl *fn.ResourceList) (*sourcedChart, error) {
+	var err error
+	sc := &sourcedChart{}
+	sc.chartData, _, sc.chartSum, err = helm.SourceChart(&job.chart.Args, "", job.uname, job.pword)
+	if err != nil {
+		return nil, err
+	}
+	sc.signer, err = helm.VerifyChart(&job.chart.Args, sc.chartData, rl, job.uname, job.pword)
+	if err != nil {
+		return nil, err
+	}
+	sc.cosignSigner, err = helm.VerifyChartCosign(&job.chart.Args, sc.chartData, rl, job.uname, job.pword)
+	if err != nil {
+		return nil, err
+	}
+	// The chart is verified before dependencies are vendored, i.e. the embedded chart sum is of the rebuilt chart
+	sc.chartData, sc.dependencies, err = helm.VendorDependencies(&job.chart.Args, sc.chartData, job.uname, job.pword)
+	if err != nil {
+		return nil, err
+	}
+	if len(sc.dependencies) > 0 {
+		sc.chartSum = fmt.Sprintf("%x", sha256.Sum256(sc.chartData))
+	}
+	return sc, nil
+}
+
+// embedChart embeds a sourced chart in its RenderHelmChart resource, Argo CD application or Flux HelmRelease
+func embedChart(job *sourceJob, sc *sourcedChart) error {
+	var err error
+	switch job.kubeObject.GetKind() {
+	case "Application":
+		err = embedArgoCDChart(job, sc)
+	case "HelmRelease":
+		err = job.kubeObject.SetNestedField(base64.StdEncoding.EncodeToString(sc.chartData), "spec", "embeddedChart")
+	default:
+		err = embedKptChart(job, sc)
+	}
+	if err != nil {
+		return err
+	}
+	err = job.kubeObject.SetAnnotation(api.HelmResourceAnnotationShaSum+"/"+job.chart.Args.Name, "sha256:"+sc.chartSum)
+	if err != nil {
+		return err
+	}
+	for _, dep := range sc.dependencies {
+		err = job.kubeObject.SetAnnotation(api.HelmResourceAnnotationDependencyVersion+"/"+job.chart.Args.Name+"/"+dep.Name, dep.Version)
+		if err != nil {
+			return err
+		}
+		if dep.Sha256Sum != "" {
+			err = job.kubeObject.SetAnnotation(api.HelmResourceAnnotationDependencyShaSum+"/"+job.chart.Args.Name+"/"+dep.Name, "sha256:"+dep.Sha256Sum)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if sc.cosignSigner != "" {
+		err = job.kubeObject.SetAnnotation(api.HelmResourceAnnotationCosignVerified+"/"+job.chart.Args.Name, "true")
+		if err != nil {
+			return err
+		}
+		err = job.kubeObject.SetAnnotation(api.HelmResourceAnnotationCosignSigner+"/"+job.chart.Args.Name, sc.cosignSigner)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func embedKptChart(job *sourceJob, sc *sourcedChart) error {
+	err := job.kubeObject.SetAPIVersion(api.HelmResourceAPIVersion)
+	if err != nil {
+		return err
+	}
+	chs, found, err := job.kubeObject.NestedSlice("helmCharts")
+	if !found {
+		return fmt.Errorf("helmCharts key not found in %s", job.kubeObject.GetName())
+	}
+	if err != nil {
+		return err
+	}
+	return chs[job.idx].SetNestedField(base64.StdEncoding.EncodeToString(sc.chartData), "chart")
+}
+
+// embedArgoCDChart embeds a chart in the 'embeddedChart' extension field of an application source
+func embedArgoCDChart(job *sourceJob, sc *sourcedChart) error {
+	chartData := base64.StdEncoding.EncodeToString(sc.chartData)
+	srcs, found, err := job.kubeObject.NestedSlice("spec", "sources")
+	if err != nil {
+		return err
+	}
+	if !found || len(srcs) == 0 {
+		return job.kubeObject.SetNestedField(chartData, "spec", "source", "embeddedChart")
+	}
+	return srcs[job.idx].SetNestedField(chartData, "embeddedChart")
+}
+
 func Run(rl *fn.ResourceList) (bool, error) {
-	var outputs fn.KubeObjects
+	if err := parseConfig(rl.FunctionConfig); err != nil {
+		return false, err
+	}
 
+	var jobs []*sourceJob
 	for _, kubeObject := range rl.Items {
 		if kubeObject.IsGVK(api.HelmResourceAPI, "", "RenderHelmChart") || kubeObject.IsGVK("fn.kpt.dev", "", "RenderHelmChart") {
 			y := kubeObject.String()
@@ -37,44 +158,88 @@ func Run(rl *fn.ResourceList) (bool, error) {
 				return false, err
 			}
 			for idx := range spec.Charts {
-				chart := &spec.Charts[idx]
-				var uname, pword string
-				if chart.Args.Auth != nil {
-					uname, pword, err = util.LookupAuthSecret(chart.Args.Auth.Name, chart.Args.Auth.Namespace, rl)
+				job := &sourceJob{kubeObject: kubeObject, chart: &spec.Charts[idx], idx: idx}
+				if job.chart.Args.Auth != nil {
+					job.uname, job.pword, err = util.LookupAuthSecret(job.chart.Args.Auth.Name, job.chart.Args.Auth.Namespace, rl)
 					if err != nil {
 						return false, err
 					}
 				}
-
-				chartData, _, chartSum, err := helm.SourceChart(&chart.Args, "", uname, pword)
-				if err != nil {
-					return false, err
-				}
-				err = kubeObject.SetAPIVersion(api.HelmResourceAPIVersion)
-				if err != nil {
-					return false, err
-				}
-				chs, found, err := kubeObject.NestedSlice("helmCharts")
-				if !found {
-					return false, fmt.Errorf("helmCharts key not found in %s", kubeObject.GetName())
-				}
-				if err != nil {
-					return false, err
+				jobs = append(jobs, job)
+			}
+		} else if kubeObject.IsGVK("argoproj.io", "", "Application") {
+			y := kubeObject.String()
+			app, err := t.ParseArgoCDSpec([]byte(y))
+			if err != nil {
+				return false, err
+			}
+			srcs := app.AllSources()
+			for idx := range srcs {
+				if !srcs[idx].IsHelmSource() {
+					continue
 				}
-				err = chs[idx].SetNestedField(base64.StdEncoding.EncodeToString(chartData), "chart")
+				job := &sourceJob{kubeObject: kubeObject, chart: &t.HelmChart{Args: srcs[idx].ToKptSpec()}, idx: idx}
+				job.uname, job.pword, err = util.LookupArgoCDAuth(kubeObject, srcs[idx].Repo, rl)
 				if err != nil {
 					return false, err
 				}
-				err = kubeObject.SetAnnotation(api.HelmResourceAnnotationShaSum+"/"+chart.Args.Name, "sha256:"+chartSum)
+				jobs = append(jobs, job)
+			}
+		} else if kubeObject.IsGVK(helm.FluxHelmAPI, "", "HelmRelease") {
+			y := kubeObject.String()
+			release, err := t.ParseFluxHelmRelease([]byte(y))
+			if err != nil {
+				return false, err
+			}
+			if !release.IsHelmSource() {
+				continue
+			}
+			repo, _, err := helm.LookupFluxRepository(release, rl.Items)
+			if err != nil {
+				return false, err
+			}
+			args, err := release.ToKptSpec(repo)
+			if err != nil {
+				return false, err
+			}
+			job := &sourceJob{kubeObject: kubeObject, chart: &t.HelmChart{Args: args}}
+			if args.Auth != nil {
+				job.uname, job.pword, err = util.LookupAuthSecret(args.Auth.Name, args.Auth.Namespace, rl)
 				if err != nil {
 					return false, err
 				}
 			}
+			jobs = append(jobs, job)
 		}
-		outputs = append(outputs, kubeObject)
 	}
 
-	rl.Items = outputs
+	// Charts are retrieved concurrently, while resources are updated sequentially in input order
+	sourced, errs := util.ParallelMap(jobs, Config.Workers, func(job *sourceJob) (*sourcedChart, error) {
+		return sourceChart(job, rl)
+	})
+	var failed []error
+	for idx := range errs {
+		if errors.Is(errs[idx], helm.ErrVerificationFailed) {
+			rl.Results = append(rl.Results, fn.ConfigObjectResult(fmt.Sprintf("chart %v: %v", jobs[idx].chart.Args.Name, errs[idx]), jobs[idx].kubeObject, fn.Error))
+		} else if errs[idx] != nil {
+			failed = append(failed, errs[idx])
+		}
+	}
+	if len(failed) > 0 {
+		return false, errors.Join(failed...)
+	}
+	if rl.Results.ExitCode() != 0 {
+		return false, nil
+	}
+
+	for idx, job := range jobs {
+		if sourced[idx].signer != "" {
+			rl.Results = append(rl.Results, fn.ConfigObjectResult(fmt.Sprintf("chart %v: provenance verified, signed by %v", job.chart.Args.Name, sourced[idx].signer), job.kubeObject, fn.Info))
+		}
+		if err := embedChart(job, sourced[idx]); err != nil {
+			return false, err
+		}
+	}
 	return true, nil
 }
 
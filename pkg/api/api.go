@@ -3,11 +3,28 @@ package api
 const (
 	HelmResourceAPI                         = "experimental.helm.sh"
 	HelmResourceAnnotationShaSum            = HelmResourceAPI + "/chart-sum"
+	HelmResourceAnnotationAuth              = HelmResourceAPI + "/chart-auth"
+	HelmResourceAnnotationRenderedBy        = HelmResourceAPI + "/rendered-by"
+	HelmResourceAnnotationRenderedShaSum    = HelmResourceAPI + "/rendered-chart-sum"
+	HelmResourceAnnotationChartName         = HelmResourceAPI + "/chart-name"
+	HelmResourceAnnotationChartVersion      = HelmResourceAPI + "/chart-version"
+	HelmResourceAnnotationTemplateSource    = HelmResourceAPI + "/template-source"
+	HelmResourceAnnotationCosignVerified    = HelmResourceAPI + "/chart-cosign-verified"
+	HelmResourceAnnotationCosignSigner      = HelmResourceAPI + "/chart-cosign-signer"
+	HelmResourceAnnotationDependencyVersion = HelmResourceAPI + "/chart-dependency-version"
+	HelmResourceAnnotationDependencyShaSum  = HelmResourceAPI + "/chart-dependency-sum"
 	HelmResourceAnnotationUpgradeAvailable  = HelmResourceAPI + "/upgrade-available"
 	HelmResourceAnnotationUpgradeConstraint = HelmResourceAPI + "/upgrade-constraint"
 	HelmResourceAnnotationUpgradeShaSum     = HelmResourceAPI + "/upgrade-chart-sum"
 	HelmResourceAPIVersion                  = HelmResourceAPI + "/v1alpha1"
 
+	// Upgrade policy overrides of a chart, see the helm-upgrader function config
+	HelmResourceAnnotationUpgradeMaxDistance   = HelmResourceAPI + "/upgrade-max-distance"
+	HelmResourceAnnotationUpgradeMinReleaseAge = HelmResourceAPI + "/upgrade-min-release-age"
+	HelmResourceAnnotationUpgradeDeny          = HelmResourceAPI + "/upgrade-deny"
+	HelmResourceAnnotationUpgradePrerelease    = HelmResourceAPI + "/upgrade-prerelease"
+	HelmResourceAnnotationUpgradeVersionScheme = HelmResourceAPI + "/upgrade-version-scheme"
+
 	KptResourceAPI = "fn.kpt.dev"
 
 	PackageUpstreamTypeGit = "git"
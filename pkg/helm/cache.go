@@ -0,0 +1,193 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	t "github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/krm-functions/catalog/pkg/util"
+)
+
+const (
+	// ChartCacheDirEnv is the environment variable defining the location of the chart cache
+	ChartCacheDirEnv = "CHART_CACHE_DIR"
+	// ChartCacheIndexTTLEnv is the environment variable defining how long repository indexes are cached, e.g. '10m'
+	ChartCacheIndexTTLEnv = "CHART_CACHE_INDEX_TTL"
+
+	DefaultIndexTTL = 5 * time.Minute
+)
+
+// ErrOffline is returned when an artifact is not found in the chart cache in offline mode
+var ErrOffline = errors.New("offline mode")
+
+// Cache is a content-addressed cache of chart tarballs and repository
+// indexes. Tarballs are stored by their sha256sum and referenced by
+// repo, name and version. Indexes, i.e. HTTP repository 'index.yaml'
+// and OCI tag lists, expire after a TTL. With an empty directory, the
+// cache is kept in memory and only shared within a single function run.
+// In offline mode, the cache is the only source of artifacts, i.e. it
+// serves as a mirror and indexes never expire
+type Cache struct {
+	dir      string
+	indexTTL time.Duration
+	offline  bool
+	mu       sync.Mutex
+	mem      map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	data    []byte
+	created time.Time
+}
+
+// chartCache is the cache used by SearchRepo and SourceChart
+var chartCache = NewCacheFromEnv()
+
+// NewCache creates a cache in dir, or an in-memory cache if dir is empty
+func NewCache(dir string, indexTTL time.Duration) *Cache {
+	return &Cache{dir: dir, indexTTL: indexTTL, mem: map[string]cacheEntry{}}
+}
+
+// NewCacheFromEnv creates a cache configured from environment
+// variables. Invalid TTLs are ignored, i.e. the default TTL is used
+func NewCacheFromEnv() *Cache {
+	ttl := DefaultIndexTTL
+	if val := os.Getenv(ChartCacheIndexTTLEnv); val != "" {
+		if d, err := time.ParseDuration(val); err == nil {
+			ttl = d
+		}
+	}
+	c := NewCache(os.Getenv(ChartCacheDirEnv), ttl)
+	c.offline = util.OfflineMode()
+	return c
+}
+
+// Offline returns true if artifacts must only be resolved from the cache
+func (c *Cache) Offline() bool {
+	return c.offline
+}
+
+// missing returns the error reported for an artifact not found in offline mode
+func (c *Cache) missing(format string, a ...any) error {
+	return fmt.Errorf("%w: %s not found in chart cache %q (%s)", ErrOffline, fmt.Sprintf(format, a...), c.dir, ChartCacheDirEnv)
+}
+
+// cacheKey returns a filesystem-safe key for a list of strings
+func cacheKey(parts ...string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(parts, "\x00"))))
+}
+
+func (c *Cache) read(name string) (data []byte, created time.Time, found bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dir == "" {
+		e, ok := c.mem[name]
+		return e.data, e.created, ok
+	}
+	path := filepath.Join(c.dir, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	data, err = os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	return data, info.ModTime(), true
+}
+
+// write stores data in the cache. Files are written atomically, since
+// caches may be shared between concurrent function runs
+func (c *Cache) write(name string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dir == "" {
+		c.mem[name] = cacheEntry{data, time.Now()}
+		return nil
+	}
+	path := filepath.Join(c.dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Chart returns a cached chart tarball. The tarball is verified
+// against its sha256sum, i.e. corrupted entries are treated as missing
+func (c *Cache) Chart(chart *t.HelmChartArgs) ([]byte, bool) {
+	sum, _, found := c.read(filepath.Join("refs", cacheKey(chart.Repo, chart.Name, chart.Version)))
+	if !found {
+		return nil, false
+	}
+	data, _, found := c.read(filepath.Join("charts", "sha256", string(sum)))
+	if !found || fmt.Sprintf("%x", sha256.Sum256(data)) != string(sum) {
+		return nil, false
+	}
+	return data, true
+}
+
+// PutChart stores a chart tarball in the cache
+func (c *Cache) PutChart(chart *t.HelmChartArgs, data []byte) error {
+	sum := fmt.Sprintf("%x", sha256.Sum256(data))
+	if err := c.write(filepath.Join("charts", "sha256", sum), data); err != nil {
+		return err
+	}
+	return c.write(filepath.Join("refs", cacheKey(chart.Repo, chart.Name, chart.Version)), []byte(sum))
+}
+
+// Index returns a cached repository index, unless it is older than the index TTL
+func (c *Cache) Index(key string) ([]byte, bool) {
+	data, created, found := c.read(filepath.Join("index", cacheKey(key)))
+	if !found || (!c.offline && time.Since(created) > c.indexTTL) {
+		return nil, false
+	}
+	return data, true
+}
+
+// Provenance returns a cached chart provenance file
+func (c *Cache) Provenance(chart *t.HelmChartArgs) ([]byte, bool) {
+	data, _, found := c.read(filepath.Join("prov", cacheKey(chart.Repo, chart.Name, chart.Version)))
+	return data, found
+}
+
+// PutProvenance stores a chart provenance file in the cache
+func (c *Cache) PutProvenance(chart *t.HelmChartArgs, data []byte) error {
+	return c.write(filepath.Join("prov", cacheKey(chart.Repo, chart.Name, chart.Version)), data)
+}
+
+// PutIndex stores a repository index in the cache
+func (c *Cache) PutIndex(key string, data []byte) error {
+	return c.write(filepath.Join("index", cacheKey(key)), data)
+}
@@ -0,0 +1,146 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/stretchr/testify/assert"
+)
+
+// useTestCache replaces the chart cache for the duration of a test
+func useTestCache(t *testing.T, c *Cache) {
+	t.Helper()
+	orig := chartCache
+	chartCache = c
+	t.Cleanup(func() { chartCache = orig })
+}
+
+func TestCache(t *testing.T) {
+	for _, dir := range []string{"", t.TempDir()} {
+		c := NewCache(dir, time.Hour)
+		chart := &helmspecs.HelmChartArgs{Name: "test-chart", Version: "0.1.0", Repo: "https://example.com"}
+		data := []byte("chart data")
+
+		_, found := c.Chart(chart)
+		assert.False(t, found)
+		if err := c.PutChart(chart, data); err != nil {
+			t.Fatal(err)
+		}
+		cached, found := c.Chart(chart)
+		assert.True(t, found)
+		assert.Equal(t, data, cached)
+
+		other := *chart
+		other.Version = "0.2.0"
+		_, found = c.Chart(&other)
+		assert.False(t, found)
+
+		if err := c.PutIndex("https://example.com/index.yaml", []byte("index")); err != nil {
+			t.Fatal(err)
+		}
+		index, found := c.Index("https://example.com/index.yaml")
+		assert.True(t, found)
+		assert.Equal(t, []byte("index"), index)
+	}
+}
+
+func TestCacheExpiredAndCorrupted(t *testing.T) {
+	dir := t.TempDir()
+	c := NewCache(dir, 0)
+	chart := &helmspecs.HelmChartArgs{Name: "test-chart", Version: "0.1.0", Repo: "https://example.com"}
+
+	if err := c.PutIndex("https://example.com/index.yaml", []byte("index")); err != nil {
+		t.Fatal(err)
+	}
+	_, found := c.Index("https://example.com/index.yaml")
+	assert.False(t, found, "expired index")
+
+	data := []byte("chart data")
+	if err := c.PutChart(chart, data); err != nil {
+		t.Fatal(err)
+	}
+	blob := filepath.Join(dir, "charts", "sha256", fmt.Sprintf("%x", sha256.Sum256(data)))
+	if err := os.WriteFile(blob, []byte("corrupted"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, found = c.Chart(chart)
+	assert.False(t, found, "corrupted chart")
+}
+
+func TestSourceChartCached(t *testing.T) {
+	useTestCache(t, NewCache(t.TempDir(), time.Hour))
+	tarball := testChartTarball(t)
+	srv := newTestHTTPRepo(t, tarball)
+	chart := &helmspecs.HelmChartArgs{Name: "test-chart", Version: "0.1.0", Repo: srv.URL}
+
+	if _, err := SearchRepo(chart, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := SourceChart(chart, "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// Cached charts and indexes are available without the repository
+	srv.Close()
+	search, err := SearchRepo(chart, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"0.1.0", "0.2.0"}, ToList(search))
+	data, _, _, err := SourceChart(chart, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, tarball, data)
+}
+
+func TestSourceChartOffline(t *testing.T) {
+	c := NewCache(t.TempDir(), 0)
+	c.offline = true
+	useTestCache(t, c)
+	chart := &helmspecs.HelmChartArgs{Name: "test-chart", Version: "0.1.0", Repo: "https://example.com"}
+
+	_, err := SearchRepo(chart, "", "")
+	assert.ErrorIs(t, err, ErrOffline)
+	_, _, _, err = SourceChart(chart, "", "", "")
+	assert.ErrorIs(t, err, ErrOffline)
+	assert.ErrorContains(t, err, "test-chart version 0.1.0")
+
+	// Charts and indexes in the cache are served, irrespective of the index TTL
+	tarball := testChartTarball(t)
+	if err = c.PutChart(chart, tarball); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.PutIndex("https://example.com/index.yaml", []byte("apiVersion: v1\nentries:\n  test-chart:\n  - name: test-chart\n    version: 0.1.0\n")); err != nil {
+		t.Fatal(err)
+	}
+	search, err := SearchRepo(chart, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"0.1.0"}, ToList(search))
+	data, _, _, err := SourceChart(chart, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, tarball, data)
+}
@@ -0,0 +1,225 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	t "github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/krm-functions/catalog/pkg/util"
+)
+
+const (
+	// CosignSignatureAnnotation holds the base64 encoded signature of a cosign signature layer
+	CosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+	// CosignSimpleSigningMediaType is the media type of cosign signature payloads
+	CosignSimpleSigningMediaType types.MediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	// CosignPublicKeyDataKey is the Secret or ConfigMap key holding cosign public keys, as written by 'cosign generate-key-pair k8s://...'
+	CosignPublicKeyDataKey = "cosign.pub"
+)
+
+// cosignPayload is the part of a cosign 'simple signing' payload used for verification
+type cosignPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// cosignKey is a public key used for verifying cosign signatures
+type cosignKey struct {
+	key         crypto.PublicKey
+	fingerprint string
+}
+
+// VerifyChartCosign verifies the cosign signature of a chart stored in
+// an OCI registry if the chart spec references cosign public keys,
+// which are looked up in the resource list. Returns the fingerprint
+// of the key that signed the chart, or an empty string if no keys are
+// referenced, i.e. the signature is not verified
+func VerifyChartCosign(chart *t.HelmChartArgs, chartData []byte, rl *fn.ResourceList, username, password string) (string, error) {
+	if chart.CosignKey == nil {
+		return "", nil
+	}
+	var publicKeys []byte
+	var err error
+	if chart.CosignKey.Kind == "ConfigMap" {
+		publicKeys, err = util.LookupConfigMapData(chart.CosignKey.Name, chart.CosignKey.Namespace, CosignPublicKeyDataKey, rl)
+	} else {
+		publicKeys, err = util.LookupSecretData(chart.CosignKey.Name, chart.CosignKey.Namespace, CosignPublicKeyDataKey, rl)
+	}
+	if err != nil {
+		return "", err
+	}
+	return VerifyCosignSignature(chart, chartData, publicKeys, username, password)
+}
+
+// VerifyCosignSignature verifies that the chart artifact in an OCI
+// registry holding chartData is signed with cosign by one of the PEM
+// encoded public keys. Only signatures made with keys are supported,
+// i.e. not keyless signatures. Returns the fingerprint of the signing key
+func VerifyCosignSignature(chart *t.HelmChartArgs, chartData, publicKeys []byte, username, password string) (string, error) {
+	signer, err := verifyCosignSignature(chart, chartData, publicKeys, username, password)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrVerificationFailed, err)
+	}
+	return signer, nil
+}
+
+func verifyCosignSignature(chart *t.HelmChartArgs, chartData, publicKeys []byte, username, password string) (string, error) {
+	if !isOciRepo(chart) {
+		return "", fmt.Errorf("cosign signatures are only supported for OCI repos")
+	}
+	if chartCache.Offline() {
+		return "", fmt.Errorf("%w: cosign signatures of chart %v cannot be verified", ErrOffline, chart.Name)
+	}
+	keys, err := parseCosignKeys(publicKeys)
+	if err != nil {
+		return "", err
+	}
+
+	ref, err := ociReference(chart)
+	if err != nil {
+		return "", err
+	}
+	opts := ociOptions(username, password)
+	img, err := remote.Image(ref, opts...)
+	if err != nil {
+		return "", fmt.Errorf("fetching %v: %w", ref, err)
+	}
+	// The signature covers the manifest, thus the chart must be a layer of the signed manifest
+	if err = checkChartLayer(img, chartData); err != nil {
+		return "", err
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return "", err
+	}
+
+	sigRef := ref.Context().Tag(fmt.Sprintf("%s-%s.sig", digest.Algorithm, digest.Hex))
+	sigImg, err := remote.Image(sigRef, opts...)
+	if err != nil {
+		return "", fmt.Errorf("fetching signatures %v: %w", sigRef, err)
+	}
+	manifest, err := sigImg.Manifest()
+	if err != nil {
+		return "", err
+	}
+	for _, desc := range manifest.Layers {
+		sig, found := desc.Annotations[CosignSignatureAnnotation]
+		if desc.MediaType != CosignSimpleSigningMediaType || !found {
+			continue
+		}
+		var payload []byte
+		payload, err = ociLayerByDigest(sigImg, desc.Digest)
+		if err != nil {
+			return "", err
+		}
+		for _, key := range keys {
+			if verifyCosignPayload(key.key, payload, sig, digest) == nil {
+				return key.fingerprint, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no valid cosign signature found for %v@%v", ref, digest)
+}
+
+// verifyCosignPayload verifies a signature of a payload and that the payload references the digest
+func verifyCosignPayload(key crypto.PublicKey, payload []byte, signature string, digest v1.Hash) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(payload)
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(k, hash[:], sig) {
+			return errors.New("invalid ECDSA signature")
+		}
+	case *rsa.PublicKey:
+		if err = rsa.VerifyPKCS1v15(k, crypto.SHA256, hash[:], sig); err != nil {
+			return err
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, payload, sig) {
+			return errors.New("invalid ED25519 signature")
+		}
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+	p := cosignPayload{}
+	if err = json.Unmarshal(payload, &p); err != nil {
+		return fmt.Errorf("parsing signature payload: %w", err)
+	}
+	if p.Critical.Image.DockerManifestDigest != digest.String() {
+		return fmt.Errorf("signature is for %v, not %v", p.Critical.Image.DockerManifestDigest, digest)
+	}
+	return nil
+}
+
+// parseCosignKeys parses one or more PEM encoded public keys
+func parseCosignKeys(data []byte) ([]cosignKey, error) {
+	var keys []cosignKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "PUBLIC KEY" {
+			continue
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing cosign public key: %w", err)
+		}
+		keys = append(keys, cosignKey{key, fmt.Sprintf("sha256:%x", sha256.Sum256(block.Bytes))})
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no PEM encoded public keys found")
+	}
+	return keys, nil
+}
+
+// checkChartLayer verifies that chartData is the chart layer of an OCI artifact
+func checkChartLayer(img v1.Image, chartData []byte) error {
+	manifest, err := img.Manifest()
+	if err != nil {
+		return err
+	}
+	sum := fmt.Sprintf("%x", sha256.Sum256(chartData))
+	for _, desc := range manifest.Layers {
+		if (desc.MediaType == ChartLayerMediaType || desc.MediaType == LegacyChartLayerMediaType) && desc.Digest.Hex == sum {
+			return nil
+		}
+	}
+	return errors.New("chart does not match chart layer of signed artifact")
+}
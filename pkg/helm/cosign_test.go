@@ -0,0 +1,111 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/stretchr/testify/assert"
+)
+
+// testCosignKey generates an ECDSA key and returns it with the PEM encoded public key
+func testCosignKey(t *testing.T) (*ecdsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+// testCosignSign signs a chart in an OCI registry the way 'cosign sign --key' does
+func testCosignSign(t *testing.T, chart *helmspecs.HelmChartArgs, key *ecdsa.PrivateKey) {
+	t.Helper()
+	ref, err := ociReference(chart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := remote.Head(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := fmt.Sprintf(`{"critical":{"identity":{"docker-reference":%q},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":null}`,
+		ref.Context().String(), desc.Digest.String())
+	hash := sha256.Sum256([]byte(payload))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer:       static.NewLayer([]byte(payload), CosignSimpleSigningMediaType),
+		Annotations: map[string]string{CosignSignatureAnnotation: base64.StdEncoding.EncodeToString(sig)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigRef := ref.Context().Tag(fmt.Sprintf("%s-%s.sig", desc.Digest.Algorithm, desc.Digest.Hex))
+	if err := remote.Write(sigRef, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyCosignSignature(t *testing.T) {
+	tarball := testChartTarball(t)
+	repo := newTestOCIRepo(t, tarball)
+	chart := &helmspecs.HelmChartArgs{Name: "test-chart", Version: "0.1.0", Repo: repo}
+	key, publicKey := testCosignKey(t)
+	_, otherPublicKey := testCosignKey(t)
+
+	_, err := VerifyCosignSignature(chart, tarball, publicKey, "", "")
+	assert.ErrorIs(t, err, ErrVerificationFailed, "unsigned chart")
+
+	testCosignSign(t, chart, key)
+	signer, err := VerifyCosignSignature(chart, tarball, publicKey, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, strings.HasPrefix(signer, "sha256:"), signer)
+
+	// Keys are tried in order
+	signer2, err := VerifyCosignSignature(chart, tarball, append(otherPublicKey, publicKey...), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, signer, signer2)
+
+	_, err = VerifyCosignSignature(chart, tarball, otherPublicKey, "", "")
+	assert.ErrorIs(t, err, ErrVerificationFailed, "wrong key")
+
+	_, err = VerifyCosignSignature(chart, append(tarball, 0), publicKey, "", "")
+	assert.ErrorIs(t, err, ErrVerificationFailed, "tampered chart")
+}
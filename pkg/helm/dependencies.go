@@ -0,0 +1,290 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	t "github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/krm-functions/catalog/pkg/semver"
+	helmchart "helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+)
+
+// Dependency is a chart dependency vendored into the 'charts/' directory of a chart
+type Dependency struct {
+	Name    string
+	Version string
+	Repo    string
+	// Sha256 sum of the dependency tarball. Empty for 'file://' dependencies, which are part of the chart tarball
+	Sha256Sum string
+}
+
+// tarEntry is a file in a chart tarball
+type tarEntry struct {
+	hdr  *tar.Header
+	data []byte
+}
+
+// VendorDependencies resolves the dependencies declared in Chart.yaml
+// which are not vendored in the 'charts/' directory of the chart and
+// returns a chart tarball with the dependencies added. Dependencies in
+// HTTP and OCI repositories are pulled using the version locked in
+// Chart.lock or, without a lock file, the highest version that
+// satisfies the version constraint of the dependency. As with 'helm
+// dependency build', a lock file out of sync with Chart.yaml is an
+// error. 'file://'
+// dependencies are resolved relative to the chart within the chart
+// tarball. The tarball is rebuilt deterministically, i.e. the same
+// chart and dependencies give the same tarball. If no dependencies are
+// missing, the chart tarball is returned unchanged
+func VendorDependencies(chart *t.HelmChartArgs, chartData []byte, username, password string) ([]byte, []Dependency, error) {
+	chrt, err := loader.LoadArchive(bytes.NewReader(chartData))
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading chart %v: %w", chart.Name, err)
+	}
+	var missing []*helmchart.Dependency
+	for _, dep := range chrt.Metadata.Dependencies {
+		if !slices.ContainsFunc(chrt.Dependencies(), func(d *helmchart.Chart) bool { return d.Name() == dep.Name }) {
+			missing = append(missing, dep)
+		}
+	}
+	if len(missing) == 0 {
+		return chartData, nil, nil
+	}
+
+	locked, err := lockedVersions(chrt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("chart %v: %w", chart.Name, err)
+	}
+	entries, err := readTarball(chartData)
+	if err != nil {
+		return nil, nil, err
+	}
+	root, err := chartRoot(entries)
+	if err != nil {
+		return nil, nil, err
+	}
+	var deps []Dependency
+	for _, dep := range missing {
+		var added []tarEntry
+		var vendored *Dependency
+		if strings.HasPrefix(dep.Repository, "file://") {
+			added, vendored, err = vendorFileDependency(dep, entries, root)
+		} else {
+			added, vendored, err = vendorRepoDependency(chart, dep, locked[dep.Name], root, username, password)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("chart %v dependency %v: %w", chart.Name, dep.Name, err)
+		}
+		entries = append(entries, added...)
+		deps = append(deps, *vendored)
+	}
+
+	data, err := writeTarball(entries)
+	if err != nil {
+		return nil, nil, err
+	}
+	chrt, err = loader.LoadArchive(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading chart %v with dependencies: %w", chart.Name, err)
+	}
+	if err = checkDependencies(chrt); err != nil {
+		return nil, nil, err
+	}
+	return data, deps, nil
+}
+
+// vendorRepoDependency pulls a dependency from a HTTP or OCI repository,
+// using the locked version if given. Credentials are only used for
+// dependencies on the host of the chart repository
+func vendorRepoDependency(chart *t.HelmChartArgs, dep *helmchart.Dependency, lockedVersion, root, username, password string) ([]tarEntry, *Dependency, error) {
+	if !strings.HasPrefix(dep.Repository, "http://") && !strings.HasPrefix(dep.Repository, "https://") && !strings.HasPrefix(dep.Repository, "oci://") {
+		return nil, nil, fmt.Errorf("unsupported repository %q, only HTTP, OCI and file:// repositories are supported", dep.Repository)
+	}
+	if !sameHost(chart.Repo, dep.Repository) {
+		username, password = "", ""
+	}
+	depArgs := &t.HelmChartArgs{Name: dep.Name, Version: lockedVersion, Repo: dep.Repository}
+	if depArgs.Version == "" {
+		search, err := SearchRepo(depArgs, username, password)
+		if err != nil {
+			return nil, nil, err
+		}
+		constraint := dep.Version
+		if constraint == "" {
+			constraint = "*"
+		}
+		depArgs.Version, err = semver.Upgrade(ToList(FilterByChartName(search, depArgs)), constraint)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	data, _, sum, err := SourceChart(depArgs, "", username, password)
+	if err != nil {
+		return nil, nil, err
+	}
+	entry := newTarEntry(path.Join(root, "charts", chartTarballName(depArgs)), data)
+	return []tarEntry{entry}, &Dependency{Name: dep.Name, Version: depArgs.Version, Repo: dep.Repository, Sha256Sum: sum}, nil
+}
+
+// errLockOutOfSync is the error of a Chart.lock not matching the dependencies of Chart.yaml
+var errLockOutOfSync = errors.New("the lock file (Chart.lock) is out of sync with the dependencies file (Chart.yaml)")
+
+// lockedVersions returns the dependency versions by name locked in the
+// Chart.lock of a chart, or nil if the chart has no lock file. The lock
+// file is out of sync if dependencies are not locked with the same
+// repository, or locked versions do not satisfy the version constraints
+func lockedVersions(chrt *helmchart.Chart) (map[string]string, error) {
+	if chrt.Lock == nil {
+		return nil, nil
+	}
+	if len(chrt.Lock.Dependencies) != len(chrt.Metadata.Dependencies) {
+		return nil, errLockOutOfSync
+	}
+	locked := map[string]string{}
+	for _, dep := range chrt.Metadata.Dependencies {
+		idx := slices.IndexFunc(chrt.Lock.Dependencies, func(l *helmchart.Dependency) bool {
+			return l.Name == dep.Name && l.Repository == dep.Repository
+		})
+		if idx < 0 {
+			return nil, fmt.Errorf("%w: dependency %v not locked", errLockOutOfSync, dep.Name)
+		}
+		version := chrt.Lock.Dependencies[idx].Version
+		if dep.Version != "" {
+			if _, err := semver.Upgrade([]string{version}, dep.Version); err != nil {
+				return nil, fmt.Errorf("%w: dependency %v locked version %v does not satisfy %v", errLockOutOfSync, dep.Name, version, dep.Version)
+			}
+		}
+		locked[dep.Name] = version
+	}
+	return locked, nil
+}
+
+// vendorFileDependency copies a 'file://' dependency within the chart tarball to the 'charts/' directory
+func vendorFileDependency(dep *helmchart.Dependency, entries []tarEntry, root string) ([]tarEntry, *Dependency, error) {
+	depPath := path.Clean(path.Join(root, strings.TrimPrefix(dep.Repository, "file://")))
+	if !strings.HasPrefix(depPath, root+"/") {
+		return nil, nil, fmt.Errorf("file:// dependency %v is outside the chart tarball", dep.Repository)
+	}
+	var added []tarEntry
+	var files []*loader.BufferedFile
+	for _, e := range entries {
+		rel, found := strings.CutPrefix(e.hdr.Name, depPath+"/")
+		if !found || e.hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		added = append(added, newTarEntry(path.Join(root, "charts", dep.Name, rel), e.data))
+		files = append(files, &loader.BufferedFile{Name: rel, Data: e.data})
+	}
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("file:// dependency %v not found in chart tarball", dep.Repository)
+	}
+	depChart, err := loader.LoadFiles(files)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading file:// dependency %v: %w", dep.Repository, err)
+	}
+	if dep.Version != "" {
+		if _, err = semver.Upgrade([]string{depChart.Metadata.Version}, dep.Version); err != nil {
+			return nil, nil, fmt.Errorf("file:// dependency %v version %v: %w", dep.Repository, depChart.Metadata.Version, err)
+		}
+	}
+	return added, &Dependency{Name: dep.Name, Version: depChart.Metadata.Version, Repo: dep.Repository}, nil
+}
+
+// chartRoot returns the top-level directory of a chart tarball, i.e. the directory holding Chart.yaml
+func chartRoot(entries []tarEntry) (string, error) {
+	for _, e := range entries {
+		dir, file := path.Split(e.hdr.Name)
+		if file == "Chart.yaml" && strings.Count(dir, "/") == 1 {
+			return strings.TrimSuffix(dir, "/"), nil
+		}
+	}
+	return "", errors.New("no Chart.yaml found in chart tarball")
+}
+
+func newTarEntry(name string, data []byte) tarEntry {
+	return tarEntry{
+		hdr: &tar.Header{
+			Name:     name,
+			Mode:     0o644,
+			Size:     int64(len(data)),
+			Typeflag: tar.TypeReg,
+			Format:   tar.FormatPAX,
+		},
+		data: data,
+	}
+}
+
+// readTarball reads the entries of a gzipped tarball. Entries larger
+// than the maximum chart file size are an error
+func readTarball(data []byte) ([]tarEntry, error) {
+	gzr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gzr.Close()
+	tr := tar.NewReader(gzr)
+	var entries []tarEntry
+	for {
+		hdr, xtErr := tr.Next()
+		if xtErr == io.EOF {
+			break
+		} else if xtErr != nil {
+			return nil, xtErr
+		}
+		// Read one byte beyond the limit to detect oversized entries instead of truncating them
+		b, rErr := io.ReadAll(io.LimitReader(tr, maxChartTemplateFileLength+1))
+		if rErr != nil {
+			return nil, rErr
+		}
+		if len(b) > maxChartTemplateFileLength {
+			return nil, fmt.Errorf("chart file %v exceeds the maximum size of %d bytes", hdr.Name, maxChartTemplateFileLength)
+		}
+		entries = append(entries, tarEntry{hdr, b})
+	}
+	return entries, nil
+}
+
+// writeTarball writes a gzipped tarball. Gzip headers carry no
+// timestamps, such that the tarball only depends on the entries
+func writeTarball(entries []tarEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	for _, e := range entries {
+		if err := tw.WriteHeader(e.hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(e.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gzw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
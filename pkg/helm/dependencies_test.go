@@ -0,0 +1,176 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// testParentChartTarball packages a chart depending on the test chart in repo and a 'file://' subchart
+func testParentChartTarball(t *testing.T, repo string) []byte {
+	t.Helper()
+	return testChartFromFiles(t, "parent", map[string]string{
+		"Chart.yaml": fmt.Sprintf(`apiVersion: v2
+name: parent
+version: 1.0.0
+dependencies:
+- name: test-chart
+  version: ~0.1.0
+  repository: %s
+- name: sub
+  version: 0.1.0
+  repository: file://sub
+`, repo),
+		"templates/cm.yaml":     "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: parent\n",
+		"sub/Chart.yaml":        "apiVersion: v2\nname: sub\nversion: 0.1.0\n",
+		"sub/templates/cm.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: sub\n",
+	})
+}
+
+// testChartFromFiles packages a chart from a map of file names to content
+func testChartFromFiles(t *testing.T, name string, files map[string]string) []byte {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), name)
+	for file, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, file)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chrt, err := loader.LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tarball, err := chartutil.Save(chrt, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestVendorDependencies(t *testing.T) {
+	tarball := testChartTarball(t)
+	srv := newTestHTTPRepo(t, tarball)
+	parent := testParentChartTarball(t, srv.URL)
+	chart := &helmspecs.HelmChartArgs{Name: "parent", Version: "1.0.0", Repo: srv.URL}
+
+	vendored, deps, err := VendorDependencies(chart, parent, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []Dependency{
+		{Name: "test-chart", Version: "0.1.0", Repo: srv.URL, Sha256Sum: fmt.Sprintf("%x", sha256.Sum256(tarball))},
+		{Name: "sub", Version: "0.1.0", Repo: "file://sub"},
+	}, deps)
+	chrt, err := loader.LoadArchive(bytes.NewReader(vendored))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, chrt.Dependencies(), 2)
+	rendered, _, err := Template(&helmspecs.HelmChart{Args: *chart, Options: helmspecs.HelmTemplateOptions{ReleaseName: "parent"}}, vendored, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(rendered), "# Source: parent/charts/sub/templates/cm.yaml")
+	assert.Contains(t, string(rendered), "# Source: parent/charts/test-chart/")
+
+	// Rebuilding is deterministic
+	again, _, err := VendorDependencies(chart, parent, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, vendored, again)
+
+	// Charts with all dependencies vendored are unchanged
+	unchanged, deps, err := VendorDependencies(chart, vendored, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, vendored, unchanged)
+	assert.Empty(t, deps)
+}
+
+func TestVendorDependenciesLocked(t *testing.T) {
+	tarball := testChartTarball(t)
+	srv := newTestHTTPRepo(t, tarball)
+	chart := &helmspecs.HelmChartArgs{Name: "parent", Version: "1.0.0", Repo: srv.URL}
+	parent := func(constraint, lockedVersion string) []byte {
+		return testChartFromFiles(t, "parent", map[string]string{
+			"Chart.yaml": fmt.Sprintf(`apiVersion: v2
+name: parent
+version: 1.0.0
+dependencies:
+- name: test-chart
+  version: %q
+  repository: %s
+`, constraint, srv.URL),
+			"Chart.lock": fmt.Sprintf(`dependencies:
+- name: test-chart
+  repository: %s
+  version: %s
+digest: sha256:0000000000000000000000000000000000000000000000000000000000000000
+generated: "2025-01-01T00:00:00Z"
+`, srv.URL, lockedVersion),
+		})
+	}
+
+	// The newest matching version is 0.2.0, but the lock pins 0.1.0
+	_, deps, err := VendorDependencies(chart, parent(">=0.1.0", "0.1.0"), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []Dependency{
+		{Name: "test-chart", Version: "0.1.0", Repo: srv.URL, Sha256Sum: fmt.Sprintf("%x", sha256.Sum256(tarball))},
+	}, deps)
+
+	// Locked versions must satisfy the constraints of Chart.yaml
+	_, _, err = VendorDependencies(chart, parent("~0.2.0", "0.1.0"), "", "")
+	assert.ErrorIs(t, err, errLockOutOfSync)
+}
+
+func TestReadTarballOversized(t *testing.T) {
+	entries := []tarEntry{newTarEntry("chart/Chart.yaml", []byte("name: chart\n"))}
+	data, err := writeTarball(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := readTarball(data)
+	assert.NoError(t, err)
+	assert.Equal(t, entries[0].data, read[0].data)
+
+	entries = append(entries, newTarEntry("chart/templates/big.yaml", make([]byte, maxChartTemplateFileLength+1)))
+	data, err = writeTarball(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = readTarball(data)
+	assert.ErrorContains(t, err, "chart/templates/big.yaml")
+}
@@ -0,0 +1,173 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Changes of objects and fields between renderings
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// ObjectDiff is the change of a rendered object between two renderings of a chart
+type ObjectDiff struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+	Change     string
+	Fields     []FieldDiff // Changed fields, only for changed objects
+}
+
+// FieldDiff is the change of a field of a rendered object. From is
+// nil for added fields and To is nil for removed fields
+type FieldDiff struct {
+	Path   string
+	Change string
+	From   any
+	To     any
+}
+
+// DiffRendered compares two renderings of a chart and returns the
+// added, removed and changed objects. Objects are identified by kind,
+// namespace and name, and are returned in the order of the renderings
+func DiffRendered(from, to []byte) ([]ObjectDiff, error) {
+	fromObjs, fromKeys, err := renderedMaps(from)
+	if err != nil {
+		return nil, err
+	}
+	toObjs, toKeys, err := renderedMaps(to)
+	if err != nil {
+		return nil, err
+	}
+	var diffs []ObjectDiff
+	for _, key := range fromKeys {
+		if _, found := toObjs[key]; !found {
+			diffs = append(diffs, objectDiff(fromObjs[key], ChangeRemoved, nil))
+		}
+	}
+	for _, key := range toKeys {
+		fromObj, found := fromObjs[key]
+		if !found {
+			diffs = append(diffs, objectDiff(toObjs[key], ChangeAdded, nil))
+			continue
+		}
+		if fields := diffFields("", fromObj, toObjs[key], nil); len(fields) > 0 {
+			diffs = append(diffs, objectDiff(toObjs[key], ChangeChanged, fields))
+		}
+	}
+	return diffs, nil
+}
+
+// renderedMaps parses rendered manifests and returns the objects by key together with the keys in rendering order
+func renderedMaps(rendered []byte) (map[string]map[string]any, []string, error) {
+	nodes, err := ParseAsRNodes(rendered)
+	if err != nil {
+		return nil, nil, err
+	}
+	objs := map[string]map[string]any{}
+	var keys []string
+	for _, node := range nodes {
+		if node.IsNilOrEmpty() || node.GetKind() == "" {
+			continue
+		}
+		m, mErr := node.Map()
+		if mErr != nil {
+			return nil, nil, fmt.Errorf("parsing %v/%v: %w", node.GetKind(), node.GetName(), mErr)
+		}
+		key := node.GetKind() + "/" + node.GetNamespace() + "/" + node.GetName()
+		if _, found := objs[key]; !found {
+			keys = append(keys, key)
+		}
+		objs[key] = m
+	}
+	return objs, keys, nil
+}
+
+func objectDiff(obj map[string]any, change string, fields []FieldDiff) ObjectDiff {
+	d := ObjectDiff{Change: change, Fields: fields}
+	d.APIVersion, _ = obj["apiVersion"].(string)
+	d.Kind, _ = obj["kind"].(string)
+	if meta, ok := obj["metadata"].(map[string]any); ok {
+		d.Namespace, _ = meta["namespace"].(string)
+		d.Name, _ = meta["name"].(string)
+	}
+	return d
+}
+
+// diffFields compares two values recursively and appends the changed fields below path
+func diffFields(path string, from, to any, diffs []FieldDiff) []FieldDiff {
+	switch f := from.(type) {
+	case map[string]any:
+		t, ok := to.(map[string]any)
+		if !ok {
+			break
+		}
+		for _, k := range slices.Sorted(maps.Keys(f)) {
+			if tv, found := t[k]; found {
+				diffs = diffFields(fieldPath(path, k), f[k], tv, diffs)
+			} else {
+				diffs = append(diffs, FieldDiff{Path: fieldPath(path, k), Change: ChangeRemoved, From: f[k]})
+			}
+		}
+		for _, k := range slices.Sorted(maps.Keys(t)) {
+			if _, found := f[k]; !found {
+				diffs = append(diffs, FieldDiff{Path: fieldPath(path, k), Change: ChangeAdded, To: t[k]})
+			}
+		}
+		return diffs
+	case []any:
+		t, ok := to.([]any)
+		if !ok {
+			break
+		}
+		for idx := range max(len(f), len(t)) {
+			p := path + "[" + strconv.Itoa(idx) + "]"
+			switch {
+			case idx >= len(t):
+				diffs = append(diffs, FieldDiff{Path: p, Change: ChangeRemoved, From: f[idx]})
+			case idx >= len(f):
+				diffs = append(diffs, FieldDiff{Path: p, Change: ChangeAdded, To: t[idx]})
+			default:
+				diffs = diffFields(p, f[idx], t[idx], diffs)
+			}
+		}
+		return diffs
+	}
+	if !reflect.DeepEqual(from, to) {
+		diffs = append(diffs, FieldDiff{Path: path, Change: ChangeChanged, From: from, To: to})
+	}
+	return diffs
+}
+
+// fieldPath appends a key to a field path. Keys with dots, e.g. label keys, are given in brackets
+func fieldPath(path, key string) string {
+	if strings.Contains(key, ".") {
+		return path + "[" + key + "]"
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
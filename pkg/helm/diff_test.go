@@ -0,0 +1,108 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testRenderedFrom = `---
+# Source: app/templates/cm.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+  labels:
+    app.kubernetes.io/version: "1.0"
+data:
+  a: "1"
+  b: "2"
+---
+# Source: app/templates/deploy.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: default
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: app:1.0
+---
+# Source: app/templates/svc.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+`
+
+const testRenderedTo = `---
+# Source: app/templates/cm.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+  labels:
+    app.kubernetes.io/version: "1.1"
+data:
+  a: "1"
+  c: "3"
+---
+# Source: app/templates/deploy.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: default
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: app:1.0
+      - name: sidecar
+        image: sidecar:1.0
+---
+# Source: app/templates/sa.yaml
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: app
+`
+
+func TestDiffRendered(t *testing.T) {
+	diffs, err := DiffRendered([]byte(testRenderedFrom), []byte(testRenderedTo))
+	assert.NoError(t, err)
+	assert.Equal(t, []ObjectDiff{
+		{APIVersion: "v1", Kind: "Service", Name: "app", Change: ChangeRemoved},
+		{APIVersion: "v1", Kind: "ConfigMap", Name: "app", Change: ChangeChanged, Fields: []FieldDiff{
+			{Path: "data.b", Change: ChangeRemoved, From: "2"},
+			{Path: "data.c", Change: ChangeAdded, To: "3"},
+			{Path: "metadata.labels[app.kubernetes.io/version]", Change: ChangeChanged, From: "1.0", To: "1.1"},
+		}},
+		{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "app", Change: ChangeChanged, Fields: []FieldDiff{
+			{Path: "spec.template.spec.containers[1]", Change: ChangeAdded, To: map[string]any{"name": "sidecar", "image": "sidecar:1.0"}},
+		}},
+		{APIVersion: "v1", Kind: "ServiceAccount", Name: "app", Change: ChangeAdded},
+	}, diffs)
+
+	diffs, err = DiffRendered([]byte(testRenderedFrom), []byte(testRenderedFrom))
+	assert.NoError(t, err)
+	assert.Empty(t, diffs)
+}
@@ -0,0 +1,138 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// TemplateError is a failure rendering a chart template
+type TemplateError struct {
+	// Chart is the name of the (sub)chart holding the template
+	Chart string
+	// Template is the template path, e.g. 'mychart/templates/deployment.yaml'
+	Template string
+	// Line and Column of the failure in the template, zero if unknown
+	Line   int
+	Column int
+	// ValuesKey is the values key being evaluated at the failure, e.g. 'image.tag', if reported by Helm
+	ValuesKey string
+	Message   string
+	err       error
+}
+
+func (e *TemplateError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Template)
+	if e.Line > 0 {
+		fmt.Fprintf(&b, ":%d", e.Line)
+		if e.Column > 0 {
+			fmt.Fprintf(&b, ":%d", e.Column)
+		}
+	}
+	if e.ValuesKey != "" {
+		fmt.Fprintf(&b, ": values %v", e.ValuesKey)
+	}
+	fmt.Fprintf(&b, ": %v", e.Message)
+	return b.String()
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.err
+}
+
+var (
+	// E.g. 'execution error at (mychart/templates/cm.yaml:3:4): msg' or 'parse error in (mychart/templates/cm.yaml): msg'
+	helmErrorRegex = regexp.MustCompile(`^(?:parse|execution) error (?:at|in) \(([^)]+)\): (?s:(.*))$`)
+	// E.g. 'template: mychart/templates/cm.yaml:3:4: executing "mychart/templates/cm.yaml" at <.Values.foo>: msg'
+	goErrorRegex = regexp.MustCompile(`^template: ([^:]+:\d+(?::\d+)?): (?s:(.*))$`)
+	// E.g. 'executing "mychart/templates/cm.yaml" at <.Values.foo.bar>: msg'
+	executingRegex = regexp.MustCompile(`^executing "[^"]*" at <([^>]*)>: (?s:(.*))$`)
+	valuesKeyRegex = regexp.MustCompile(`\.Values((?:\.[\w-]+)+)`)
+)
+
+// templateError parses a Helm template rendering error into a
+// TemplateError. Errors in other formats are returned unchanged
+func templateError(err error) error {
+	msg := err.Error()
+	var location string
+	if m := helmErrorRegex.FindStringSubmatch(msg); m != nil {
+		location, msg = m[1], m[2]
+	} else if m := goErrorRegex.FindStringSubmatch(msg); m != nil {
+		location, msg = m[1], m[2]
+	} else {
+		return err
+	}
+	te := &TemplateError{Message: msg, err: err}
+	te.Template, te.Line, te.Column = parseLocation(location)
+	te.Chart = templateChart(te.Template)
+	if m := executingRegex.FindStringSubmatch(msg); m != nil {
+		te.Message = m[2]
+		if v := valuesKeyRegex.FindStringSubmatch(m[1]); v != nil {
+			te.ValuesKey = valuesKey(te.Template, strings.TrimPrefix(v[1], "."))
+		}
+	}
+	return te
+}
+
+// valuesKey returns the key of the chart values of a key of the
+// values of the (sub)chart holding a template, i.e. keys of subcharts
+// are prefixed with the subchart path as with ValidateValues. Global
+// values are shared and not prefixed
+func valuesKey(template, key string) string {
+	if key == "global" || strings.HasPrefix(key, "global.") {
+		return key
+	}
+	return joinField(strings.Join(templateSubcharts(template), "."), key)
+}
+
+// parseLocation splits a 'file', 'file:line' or 'file:line:column' location
+func parseLocation(location string) (file string, line, column int) {
+	parts := strings.Split(location, ":")
+	file = parts[0]
+	if len(parts) > 1 {
+		line, _ = strconv.Atoi(parts[1])
+	}
+	if len(parts) > 2 {
+		column, _ = strconv.Atoi(parts[2])
+	}
+	return file, line, column
+}
+
+// templateChart returns the name of the chart holding a template,
+// e.g. 'sub' for 'parent/charts/sub/templates/cm.yaml'
+func templateChart(template string) string {
+	if subcharts := templateSubcharts(template); len(subcharts) > 0 {
+		return subcharts[len(subcharts)-1]
+	}
+	return strings.Split(template, "/")[0]
+}
+
+// templateSubcharts returns the subchart path of a template, e.g.
+// ['sub', 'nested'] for 'parent/charts/sub/charts/nested/templates/cm.yaml'
+func templateSubcharts(template string) []string {
+	parts := strings.Split(template, "/")
+	var subcharts []string
+	for i := 1; i < len(parts)-1 && parts[i] != "templates"; i++ {
+		if parts[i] == "charts" {
+			subcharts = append(subcharts, parts[i+1])
+			i++
+		}
+	}
+	return subcharts
+}
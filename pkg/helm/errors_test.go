@@ -0,0 +1,86 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"errors"
+	"maps"
+	"testing"
+
+	"github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateError(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		files    map[string]string
+		expected TemplateError
+	}{
+		{
+			name:     "required",
+			template: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ required \"name is required\" .Values.name }}\n",
+			expected: TemplateError{Chart: "broken", Template: "broken/templates/cm.yaml", Line: 4, Column: 11, Message: "name is required"},
+		},
+		{
+			name:     "nil pointer",
+			template: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .Values.image.tag }}\n",
+			expected: TemplateError{Chart: "broken", Template: "broken/templates/cm.yaml", Line: 4, Column: 18, ValuesKey: "image.tag",
+				Message: "nil pointer evaluating interface {}.tag"},
+		},
+		{
+			name: "subchart",
+			files: map[string]string{
+				"charts/sub/Chart.yaml":        "apiVersion: v2\nname: sub\nversion: 0.1.0\n",
+				"charts/sub/templates/cm.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .Values.image.tag }}\n",
+			},
+			expected: TemplateError{Chart: "sub", Template: "broken/charts/sub/templates/cm.yaml", Line: 4, Column: 18, ValuesKey: "sub.image.tag",
+				Message: "nil pointer evaluating interface {}.tag"},
+		},
+		{
+			name:     "parse error",
+			template: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .Values.name \n",
+			expected: TemplateError{Chart: "broken", Template: "broken/templates/cm.yaml", Line: 5, Message: "unclosed action started at broken/templates/cm.yaml:4"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			files := map[string]string{"Chart.yaml": "apiVersion: v2\nname: broken\nversion: 0.1.0\n"}
+			if tc.template != "" {
+				files["templates/cm.yaml"] = tc.template
+			}
+			maps.Copy(files, tc.files)
+			tarball := testChartFromFiles(t, "broken", files)
+			chart := &helmspecs.HelmChart{Options: helmspecs.HelmTemplateOptions{ReleaseName: "broken"}}
+			_, _, err := Template(chart, tarball, nil, nil)
+			var te *TemplateError
+			if !errors.As(err, &te) {
+				t.Fatalf("expected TemplateError, got %v", err)
+			}
+			assert.Equal(t, tc.expected.Chart, te.Chart)
+			assert.Equal(t, tc.expected.Template, te.Template)
+			assert.Equal(t, tc.expected.Line, te.Line)
+			assert.Equal(t, tc.expected.Column, te.Column)
+			assert.Equal(t, tc.expected.ValuesKey, te.ValuesKey)
+			assert.Equal(t, tc.expected.Message, te.Message)
+		})
+	}
+
+	assert.Equal(t, "sub", templateChart("parent/charts/sub/templates/charts/cm.yaml"))
+	assert.Equal(t, "parent", templateChart("parent/templates/cm.yaml"))
+	assert.Equal(t, "sub.nested.image.tag", valuesKey("parent/charts/sub/charts/nested/templates/cm.yaml", "image.tag"))
+	assert.Equal(t, "global.image", valuesKey("parent/charts/sub/templates/cm.yaml", "global.image"))
+}
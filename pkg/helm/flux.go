@@ -0,0 +1,114 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	t "github.com/krm-functions/catalog/pkg/helmspecs"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/strvals"
+)
+
+const (
+	FluxHelmAPI   = "helm.toolkit.fluxcd.io"
+	FluxSourceAPI = "source.toolkit.fluxcd.io"
+
+	fluxDefaultValuesKey = "values.yaml"
+)
+
+// LookupFluxRepository returns the HelmRepository or OCIRepository
+// holding the chart of a release, and the object it was parsed from
+func LookupFluxRepository(release *t.FluxHelmRelease, objects fn.KubeObjects) (*t.FluxRepository, *fn.KubeObject, error) {
+	ref := release.SourceRef()
+	for _, o := range objects {
+		if !o.IsGVK(FluxSourceAPI, "", ref.Kind) || o.GetName() != ref.Name || o.GetNamespace() != ref.Namespace {
+			continue
+		}
+		repo, err := t.ParseFluxRepository([]byte(o.String()))
+		if err != nil {
+			return nil, nil, err
+		}
+		return repo, o, nil
+	}
+	return nil, nil, fmt.Errorf("HelmRelease %s: %s %s/%s not found", release.Metadata.Name, ref.Kind, ref.Namespace, ref.Name)
+}
+
+// FluxValues returns the values of a release, i.e. the values of
+// 'valuesFrom' merged in order, with the values of the release on
+// top. ConfigMaps and Secrets of 'valuesFrom' are looked up in the
+// namespace of the release among `objects`
+func FluxValues(release *t.FluxHelmRelease, objects fn.KubeObjects) (map[string]any, error) {
+	values := map[string]any{}
+	for _, ref := range release.Spec.ValuesFrom {
+		key := ref.ValuesKey
+		if key == "" {
+			key = fluxDefaultValuesKey
+		}
+		data, found, err := fluxValuesData(&ref, key, release.Metadata.Namespace, objects)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			if ref.Optional {
+				continue
+			}
+			return nil, fmt.Errorf("HelmRelease %s: values key %q of %s %s not found", release.Metadata.Name, key, ref.Kind, ref.Name)
+		}
+		if ref.TargetPath != "" {
+			if err = strvals.ParseInto(ref.TargetPath+"="+string(data), values); err != nil {
+				return nil, fmt.Errorf("HelmRelease %s: setting values of %s %s at %s: %w", release.Metadata.Name, ref.Kind, ref.Name, ref.TargetPath, err)
+			}
+			continue
+		}
+		vals, err := chartutil.ReadValues(data)
+		if err != nil {
+			return nil, fmt.Errorf("HelmRelease %s: parsing values of %s %s: %w", release.Metadata.Name, ref.Kind, ref.Name, err)
+		}
+		values = mergeValues(values, vals)
+	}
+	return mergeValues(values, release.Spec.Values), nil
+}
+
+// fluxValuesData returns the data of a key of a ConfigMap or Secret
+func fluxValuesData(ref *t.FluxValuesReference, key, namespace string, objects fn.KubeObjects) ([]byte, bool, error) {
+	if ref.Kind != "ConfigMap" && ref.Kind != "Secret" {
+		return nil, false, fmt.Errorf("unsupported valuesFrom kind: %s", ref.Kind)
+	}
+	for _, o := range objects {
+		if !o.IsGVK("", "v1", ref.Kind) || o.GetName() != ref.Name || o.GetNamespace() != namespace {
+			continue
+		}
+		if ref.Kind == "Secret" {
+			if data, found, _ := o.NestedString("stringData", key); found {
+				return []byte(data), true, nil
+			}
+			data, found, _ := o.NestedString("data", key)
+			if !found {
+				return nil, false, nil
+			}
+			b, err := base64.StdEncoding.DecodeString(data)
+			if err != nil {
+				return nil, false, fmt.Errorf("decoding %q of Secret %s: %w", key, ref.Name, err)
+			}
+			return b, true, nil
+		}
+		data, found, _ := o.NestedString("data", key)
+		return []byte(data), found, nil
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,167 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/stretchr/testify/assert"
+)
+
+const testFluxObjects = `apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: podinfo
+  namespace: apps
+spec:
+  targetNamespace: podinfo
+  chart:
+    spec:
+      chart: podinfo
+      version: 6.5.0
+      sourceRef:
+        kind: HelmRepository
+        name: podinfo
+  values:
+    replicaCount: 3
+    image:
+      tag: 6.5.1
+  valuesFrom:
+  - kind: ConfigMap
+    name: podinfo-values
+  - kind: Secret
+    name: podinfo-secret
+    valuesKey: password
+    targetPath: auth.password
+  - kind: ConfigMap
+    name: missing
+    optional: true
+---
+apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: podinfo-oci
+  namespace: apps
+spec:
+  chartRef:
+    kind: OCIRepository
+    name: podinfo
+    namespace: flux-system
+---
+apiVersion: source.toolkit.fluxcd.io/v1
+kind: HelmRepository
+metadata:
+  name: podinfo
+  namespace: apps
+spec:
+  url: https://stefanprodan.github.io/podinfo
+  secretRef:
+    name: repo-auth
+---
+apiVersion: source.toolkit.fluxcd.io/v1beta2
+kind: OCIRepository
+metadata:
+  name: podinfo
+  namespace: flux-system
+spec:
+  url: oci://ghcr.io/stefanprodan/charts/podinfo
+  ref:
+    tag: 6.5.0
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: podinfo-values
+  namespace: apps
+data:
+  values.yaml: |
+    replicaCount: 2
+    image:
+      repository: ghcr.io/stefanprodan/podinfo
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: podinfo-secret
+  namespace: apps
+data:
+  password: c2VjcmV0
+`
+
+func TestFluxHelmRelease(t *testing.T) {
+	objects, err := fn.ParseKubeObjects([]byte(testFluxObjects))
+	if err != nil {
+		t.Fatal(err)
+	}
+	release, err := helmspecs.ParseFluxHelmRelease([]byte(objects[0].String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, release.IsHelmSource())
+	repo, repoObject, err := LookupFluxRepository(release, objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, objects[2], repoObject)
+	args, err := release.ToKptSpec(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "podinfo", args.Name)
+	assert.Equal(t, "6.5.0", args.Version)
+	assert.Equal(t, "https://stefanprodan.github.io/podinfo", args.Repo)
+	assert.Equal(t, "repo-auth", args.Auth.Name)
+	assert.Equal(t, "apps", args.Auth.Namespace)
+
+	values, err := FluxValues(release, objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]any{
+		"replicaCount": 3,
+		"image":        map[string]any{"repository": "ghcr.io/stefanprodan/podinfo", "tag": "6.5.1"},
+		"auth":         map[string]any{"password": "secret"},
+	}, values)
+
+	chart := release.ToHelmChart(args, values)
+	assert.Equal(t, "podinfo-podinfo", chart.Options.ReleaseName)
+	assert.Equal(t, "podinfo", chart.Options.Namespace)
+	assert.True(t, chart.Options.IncludeCRDs)
+
+	// Charts of OCI repositories
+	release, err = helmspecs.ParseFluxHelmRelease([]byte(objects[1].String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, _, err = LookupFluxRepository(release, objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args, err = release.ToKptSpec(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, helmspecs.HelmChartArgs{Name: "podinfo", Version: "6.5.0", Repo: "oci://ghcr.io/stefanprodan/charts"}, args)
+	chart = release.ToHelmChart(args, nil)
+	assert.Equal(t, "podinfo-oci", chart.Options.ReleaseName)
+	assert.Equal(t, "apps", chart.Options.Namespace)
+
+	// Missing values are errors unless optional
+	release.Spec.ValuesFrom = []helmspecs.FluxValuesReference{{Kind: "ConfigMap", Name: "missing"}}
+	_, err = FluxValues(release, objects)
+	assert.Error(t, err)
+}
@@ -15,17 +15,14 @@
 package helm
 
 import (
-	"bytes"
 	"crypto/sha256"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	t "github.com/krm-functions/catalog/pkg/helmspecs"
-	"github.com/krm-functions/catalog/pkg/skopeo"
-	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
 type RepoSearch struct {
@@ -33,186 +30,70 @@ type RepoSearch struct {
 	AppVersion  string `yaml:"app_version"`
 	Name        string `yaml:"name"`
 	Description string `yaml:"description"`
+	// Created is the release time of the version, if known
+	Created time.Time `yaml:"created"`
 }
 
-type RunContext struct {
-	repoConfigDir string
-	repoConfig    string
-}
-
-func NewRunContext() *RunContext {
-	// To avoid modifying local Helm config file, we run Helm using a temporary repo config
-	repoCfgDir, err := os.MkdirTemp("", "helm-repo-cfg")
-	if err != nil {
-		panic(fmt.Errorf("error creating temp Helm config dir: %q", err.Error()))
-	}
-	return &RunContext{repoCfgDir, filepath.Join(repoCfgDir, "repository")}
-}
-
-func (ctxt *RunContext) DiscardContext() {
-	os.RemoveAll(ctxt.repoConfigDir)
-}
-
-func (ctxt *RunContext) Run(args ...string) ([]byte, error) {
-	a := append([]string{"--repository-config", ctxt.repoConfig}, args...)
-	cmd := exec.Command("helm", a...)
-	stdout := new(bytes.Buffer)
-	stderr := new(bytes.Buffer)
-	cmd.Stdout = stdout
-	cmd.Stderr = stderr
-	err := cmd.Run()
-	if err != nil {
-		return nil, fmt.Errorf("error running helm command: %q: %q (%q)", args, stderr, err.Error())
-	}
-	return stdout.Bytes(), nil
-}
-
+// SearchRepo lists the versions of a chart available in a HTTP or OCI chart repository
 func SearchRepo(chart *t.HelmChartArgs, username, password string) ([]RepoSearch, error) {
 	if isOciRepo(chart) {
-		ociSearch, err := skopeo.ListTags(chart, username, password)
-		if err != nil {
-			return nil, err
-		}
-		versions := make([]RepoSearch, len(ociSearch.Tags))
-		for idx, v := range ociSearch.Tags {
-			versions[idx].Name = chart.Name
-			versions[idx].Version = v
-		}
-		return versions, nil
+		return searchOCIRepo(chart, username, password)
 	}
-
-	// Plain HTTP Helm repo
-	helmCtxt := NewRunContext()
-	defer helmCtxt.DiscardContext()
-
-	addArgs := []string{"repo", "add", "tmprepo", chart.Repo}
-	if username != "" && password != "" {
-		addArgs = append(addArgs, "--username", username, "--password", password)
-	}
-	_, err := helmCtxt.Run(addArgs...)
-	if err != nil {
-		return nil, err
-	}
-
-	_, err = helmCtxt.Run("repo", "update")
-	if err != nil {
-		return nil, err
-	}
-
-	// Search repo for chart, long listing in yaml format. May include other charts partially matching search
-	out, err := helmCtxt.Run("search", "repo", "-l", "-o", "yaml", chart.Name)
-	if err != nil {
-		return nil, err
-	}
-
-	var versions []RepoSearch
-	if err := kyaml.Unmarshal(out, &versions); err != nil {
-		return nil, fmt.Errorf("error parsing helm output: %q", err.Error())
-	}
-
-	// Normalize by stripping 'tmprepo/' chart name prefix
-	versionsNormalized := make([]RepoSearch, len(versions))
-	for idx, v := range versions {
-		v.Name = strings.TrimPrefix(v.Name, "tmprepo/")
-		versionsNormalized[idx] = v
-	}
-
-	return versionsNormalized, nil
+	return searchHTTPRepo(chart, username, password)
 }
 
-// PullChart runs 'helm pull' and returns normalized tarball filename and tarball sha256sum
+// PullChart retrieves a chart from a HTTP or OCI chart repository and
+// writes it to destinationPath, unless destinationPath is empty.
+// Returns normalized tarball filename and tarball sha256sum
 func PullChart(chart *t.HelmChartArgs, destinationPath, username, password string) (tarballName, chartSha256Sum string, err error) {
-	helmCtxt := NewRunContext()
-	defer helmCtxt.DiscardContext()
+	_, tarballName, chartSha256Sum, err = SourceChart(chart, destinationPath, username, password)
+	return tarballName, chartSha256Sum, err
+}
 
-	var dest string
-	if destinationPath == "" {
-		dest = helmCtxt.repoConfigDir
-	} else {
-		dest = destinationPath
+// SourceChart retrieves a chart from the chart cache or a HTTP or OCI
+// chart repository and returns raw tarball bytes. If destination is
+// defined, the tarball is also written to destination. If destination
+// is not defined, only the returned chartData can be used and not the
+// tarballName
+func SourceChart(chart *t.HelmChartArgs, destination, username, password string) (chartData []byte, tarballName, chartSha256Sum string, err error) {
+	chartData, found := chartCache.Chart(chart)
+	if !found && chartCache.Offline() {
+		return nil, "", "", chartCache.missing("chart %v version %v from %v", chart.Name, chart.Version, chart.Repo)
 	}
-
-	if isOciRepo(chart) {
-		if username != "" && password != "" {
-			loginArgs := []string{"registry", "login", strings.TrimPrefix(chart.Repo, "oci://")}
-			loginArgs = append(loginArgs, "--username", username, "--password", password)
-			_, err := helmCtxt.Run(loginArgs...)
-			if err != nil {
-				return "", "", fmt.Errorf("registry login: %w", err)
-			}
-		}
-		_, err := helmCtxt.Run("pull", chart.Repo+"/"+chart.Name, "--version", chart.Version, "--destination", dest)
+	if !found {
+		chartData, err = pullChart(chart, username, password)
 		if err != nil {
-			return "", "", fmt.Errorf("pulling chart (oci): %w", err)
+			return nil, "", "", err
 		}
-	} else {
-		repoAlias := "tmprepo"
-		addArgs := []string{"repo", "add", repoAlias, chart.Repo}
-		if username != "" && password != "" {
-			addArgs = append(addArgs, "--username", username, "--password", password)
-		}
-		_, err := helmCtxt.Run(addArgs...)
-		if err != nil {
-			return "", "", fmt.Errorf("adding repo: %w", err)
-		}
-		_, err = helmCtxt.Run("repo", "update")
-		if err != nil {
-			return "", "", fmt.Errorf("updating repo: %w", err)
-		}
-		_, err = helmCtxt.Run("pull", repoAlias+"/"+chart.Name, "--version", chart.Version, "--destination", dest)
-		if err != nil {
-			return "", "", fmt.Errorf("pulling chart: %w", err)
+		if err = chartCache.PutChart(chart, chartData); err != nil {
+			return nil, "", "", fmt.Errorf("caching chart: %w", err)
 		}
 	}
+	chartSum := fmt.Sprintf("%x", sha256.Sum256(chartData))
 
+	if destination == "" {
+		return chartData, "", chartSum, nil
+	}
 	tarball := chartTarballName(chart)
-	if _, err := os.Stat(tarball); err != nil {
-		options, err := os.ReadDir(dest)
-		if err != nil {
-			return "", "", fmt.Errorf("read dir with chart tarball: %w", err)
-		}
-		if len(options) != 1 {
-			return "", "", fmt.Errorf("cannot determine normalized tarball name, found %d files", len(options))
-		}
-		tarball = options[0].Name()
+	if err := os.WriteFile(filepath.Join(destination, tarball), chartData, 0o600); err != nil {
+		return nil, "", "", fmt.Errorf("writing chart tarball: %w", err)
 	}
-	chartShaSum := ChartFileSha256(filepath.Join(dest, tarball)) // TODO: Compare with .prov file content
-
-	return tarball, chartShaSum, nil
+	return chartData, tarball, chartSum, nil
 }
 
-// SourceChart runs PullChart to retrieve chart and reads and returns raw tarball bytes.
-// If destination is not defined, a temporary directory will be used, and cleaned-up at function
-// exit. This means that only the returned chartData can be used and not the tarballName
-func SourceChart(chart *t.HelmChartArgs, destination, username, password string) (chartData []byte, tarballName, chartSha256Sum string, err error) {
-	var tmpDir string
-	if destination == "" {
-		tmpDir, err = os.MkdirTemp("", "chart-")
+func pullChart(chart *t.HelmChartArgs, username, password string) ([]byte, error) {
+	if isOciRepo(chart) {
+		chartData, err := pullOCIChart(chart, username, password)
 		if err != nil {
-			return nil, "", "", fmt.Errorf("creating tmp dir: %w", err)
+			return nil, fmt.Errorf("pulling chart (oci): %w", err)
 		}
-		defer os.RemoveAll(tmpDir)
-	} else {
-		tmpDir = destination
+		return chartData, nil
 	}
-
-	tarball, chartSum, err := PullChart(chart, tmpDir, username, password)
-	if err != nil {
-		return nil, "", "", err
-	}
-	buf, err := os.ReadFile(filepath.Join(tmpDir, tarball))
+	chartData, err := pullHTTPChart(chart, username, password)
 	if err != nil {
-		return nil, "", "", fmt.Errorf("reading chart bytes: %w", err)
+		return nil, fmt.Errorf("pulling chart: %w", err)
 	}
-	if destination == "" {
-		// tarball is deleted, i.e. return empty string
-		return buf, "", chartSum, err
-	}
-	return buf, tarball, chartSum, err
-}
-
-func isOciRepo(chart *t.HelmChartArgs) bool {
-	return strings.HasPrefix(chart.Repo, "oci://")
+	return chartData, nil
 }
 
 // chartTarballName returns the normalized tarball name 'name-v1.2.3.tgz'
@@ -0,0 +1,179 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	t "github.com/krm-functions/catalog/pkg/helmspecs"
+	"helm.sh/helm/v3/pkg/release"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	ArgoCDHookAnnotation             = "argocd.argoproj.io/hook"
+	ArgoCDHookDeletePolicyAnnotation = "argocd.argoproj.io/hook-delete-policy"
+	ArgoCDSyncWaveAnnotation         = "argocd.argoproj.io/sync-wave"
+	FluxForceAnnotation              = "kustomize.toolkit.fluxcd.io/force"
+)
+
+// argoCDHookPhases maps Helm hook events to Argo CD hook phases. Other events have no Argo CD equivalent
+var argoCDHookPhases = map[release.HookEvent]string{
+	release.HookPreInstall:  "PreSync",
+	release.HookPreUpgrade:  "PreSync",
+	release.HookPostInstall: "PostSync",
+	release.HookPostUpgrade: "PostSync",
+}
+
+var installUpgradeEvents = []release.HookEvent{
+	release.HookPreInstall,
+	release.HookPostInstall,
+	release.HookPreUpgrade,
+	release.HookPostUpgrade,
+}
+
+var argoCDHookDeletePolicies = map[release.HookDeletePolicy]string{
+	release.HookSucceeded:          "HookSucceeded",
+	release.HookFailed:             "HookFailed",
+	release.HookBeforeHookCreation: "BeforeHookCreation",
+}
+
+// helmHookAnnotations are removed from hooks translated to Argo CD or Flux
+var helmHookAnnotations = []string{
+	release.HookAnnotation,
+	release.HookWeightAnnotation,
+	release.HookDeleteAnnotation,
+	release.HookOutputLogAnnotation,
+}
+
+// renderHooks returns the hooks to render according to the `hooks`
+// template option. With 'keep', hooks are rendered in the order of
+// 'helm template'. With 'argocd', hooks of install and upgrade events
+// are translated to Argo CD PreSync and PostSync hooks, with the hook
+// weight as sync wave, and ordered by weight and name, i.e. the order
+// in which Helm executes hooks. With 'flux', hooks of install and
+// upgrade events are rendered as ordinary objects, which Flux
+// re-creates when immutable fields change, e.g. of Jobs. Flux applies
+// these together with other objects, i.e. the ordering by weight is
+// lost and returned as warnings. Hooks of other events, e.g. tests,
+// have no equivalent and are dropped when translating
+func renderHooks(hooks []*release.Hook, opts *t.HelmTemplateOptions) ([]*release.Hook, []string, error) {
+	if opts.Hooks == t.HooksDrop {
+		return nil, nil, nil
+	}
+	var result []*release.Hook
+	var warnings []string
+	for _, h := range hooks {
+		if opts.SkipTests && isTestHook(h) {
+			continue
+		}
+		translated := h
+		var warning string
+		var err error
+		switch opts.Hooks {
+		case t.HooksArgoCD:
+			translated, err = argoCDHook(h)
+		case t.HooksFlux:
+			translated, warning, err = fluxHook(h)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("translating hook %v: %w", h.Path, err)
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+		if translated != nil {
+			result = append(result, translated)
+		}
+	}
+	if opts.Hooks == t.HooksArgoCD {
+		slices.SortStableFunc(result, func(a, b *release.Hook) int {
+			return cmp.Or(cmp.Compare(a.Weight, b.Weight), cmp.Compare(a.Name, b.Name))
+		})
+	}
+	return result, warnings, nil
+}
+
+// argoCDHook translates a Helm hook to an Argo CD hook. Returns nil if no event has an Argo CD equivalent
+func argoCDHook(h *release.Hook) (*release.Hook, error) {
+	var phases []string
+	for _, ev := range h.Events {
+		if phase, found := argoCDHookPhases[ev]; found && !slices.Contains(phases, phase) {
+			phases = append(phases, phase)
+		}
+	}
+	if len(phases) == 0 {
+		return nil, nil
+	}
+	var policies []string
+	for _, p := range h.DeletePolicies {
+		if policy, found := argoCDHookDeletePolicies[p]; found {
+			policies = append(policies, policy)
+		}
+	}
+	annotations := map[string]string{ArgoCDHookAnnotation: strings.Join(phases, ",")}
+	if h.Weight != 0 {
+		annotations[ArgoCDSyncWaveAnnotation] = strconv.Itoa(h.Weight)
+	}
+	if len(policies) > 0 {
+		annotations[ArgoCDHookDeletePolicyAnnotation] = strings.Join(policies, ",")
+	}
+	return translateHook(h, annotations)
+}
+
+// fluxHook translates a Helm hook to an ordinary object. Returns nil
+// for hooks not run on install or upgrade, and a warning for hooks with
+// a weight, since Flux does not order objects
+func fluxHook(h *release.Hook) (*release.Hook, string, error) {
+	if !slices.ContainsFunc(h.Events, func(ev release.HookEvent) bool { return slices.Contains(installUpgradeEvents, ev) }) {
+		return nil, "", nil
+	}
+	var warning string
+	if h.Weight != 0 {
+		warning = fmt.Sprintf("hook %v: weight %d ignored, Flux applies hooks together with other objects", h.Path, h.Weight)
+	}
+	translated, err := translateHook(h, map[string]string{FluxForceAnnotation: "enabled"})
+	return translated, warning, err
+}
+
+// translateHook returns a copy of a hook with Helm hook annotations replaced by annotations
+func translateHook(h *release.Hook, annotations map[string]string) (*release.Hook, error) {
+	node, err := kyaml.Parse(h.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range helmHookAnnotations {
+		if _, err = node.Pipe(kyaml.ClearAnnotation(a)); err != nil {
+			return nil, err
+		}
+	}
+	for _, k := range slices.Sorted(maps.Keys(annotations)) {
+		if err = node.PipeE(kyaml.SetAnnotation(k, annotations[k])); err != nil {
+			return nil, err
+		}
+	}
+	manifest, err := node.String()
+	if err != nil {
+		return nil, err
+	}
+	translated := *h
+	translated.Manifest = strings.TrimSuffix(manifest, "\n")
+	return &translated, nil
+}
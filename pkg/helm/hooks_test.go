@@ -0,0 +1,127 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"testing"
+
+	"github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/release"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func testHooks() []*release.Hook {
+	return []*release.Hook{
+		{
+			Name: "migrate", Kind: "Job", Path: "chart/templates/migrate.yaml", Weight: 5,
+			Events:         []release.HookEvent{release.HookPreInstall, release.HookPreUpgrade},
+			DeletePolicies: []release.HookDeletePolicy{release.HookBeforeHookCreation, release.HookSucceeded},
+			Manifest: `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    helm.sh/hook: pre-install,pre-upgrade
+    helm.sh/hook-weight: "5"
+    helm.sh/hook-delete-policy: before-hook-creation,hook-succeeded
+    team: db`,
+		},
+		{
+			Name: "setup", Kind: "Job", Path: "chart/templates/setup.yaml", Weight: -1,
+			Events: []release.HookEvent{release.HookPostInstall},
+			Manifest: `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: setup
+  annotations:
+    helm.sh/hook: post-install
+    helm.sh/hook-weight: "-1"`,
+		},
+		{
+			Name: "test", Kind: "Pod", Path: "chart/templates/test.yaml",
+			Events: []release.HookEvent{release.HookTest},
+			Manifest: `apiVersion: v1
+kind: Pod
+metadata:
+  name: test
+  annotations:
+    helm.sh/hook: test`,
+		},
+	}
+}
+
+func hookAnnotations(t *testing.T, h *release.Hook) map[string]string {
+	t.Helper()
+	node, err := kyaml.Parse(h.Manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return node.GetAnnotations()
+}
+
+func TestRenderHooks(t *testing.T) {
+	// Hooks are kept in the order of 'helm template'
+	hooks, warnings, err := renderHooks(testHooks(), &helmspecs.HelmTemplateOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, []string{"migrate", "setup", "test"}, hookNames(hooks))
+	assert.Equal(t, testHooks()[0].Manifest, hooks[0].Manifest)
+
+	hooks, _, err = renderHooks(testHooks(), &helmspecs.HelmTemplateOptions{Hooks: helmspecs.HooksKeep, SkipTests: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"migrate", "setup"}, hookNames(hooks))
+
+	hooks, _, err = renderHooks(testHooks(), &helmspecs.HelmTemplateOptions{Hooks: helmspecs.HooksDrop})
+	assert.NoError(t, err)
+	assert.Empty(t, hooks)
+}
+
+func TestRenderHooksArgoCD(t *testing.T) {
+	hooks, warnings, err := renderHooks(testHooks(), &helmspecs.HelmTemplateOptions{Hooks: helmspecs.HooksArgoCD})
+	assert.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, []string{"setup", "migrate"}, hookNames(hooks))
+	assert.Equal(t, map[string]string{
+		ArgoCDHookAnnotation:     "PostSync",
+		ArgoCDSyncWaveAnnotation: "-1",
+	}, hookAnnotations(t, hooks[0]))
+	assert.Equal(t, map[string]string{
+		ArgoCDHookAnnotation:             "PreSync",
+		ArgoCDSyncWaveAnnotation:         "5",
+		ArgoCDHookDeletePolicyAnnotation: "BeforeHookCreation,HookSucceeded",
+		"team":                           "db",
+	}, hookAnnotations(t, hooks[1]))
+}
+
+func TestRenderHooksFlux(t *testing.T) {
+	hooks, warnings, err := renderHooks(testHooks(), &helmspecs.HelmTemplateOptions{Hooks: helmspecs.HooksFlux})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"migrate", "setup"}, hookNames(hooks))
+	assert.Equal(t, map[string]string{FluxForceAnnotation: "enabled", "team": "db"}, hookAnnotations(t, hooks[0]))
+	// Flux does not order objects by hook weight
+	assert.Equal(t, []string{
+		"hook chart/templates/migrate.yaml: weight 5 ignored, Flux applies hooks together with other objects",
+		"hook chart/templates/setup.yaml: weight -1 ignored, Flux applies hooks together with other objects",
+	}, warnings)
+}
+
+func hookNames(hooks []*release.Hook) []string {
+	var names []string
+	for _, h := range hooks {
+		names = append(names, h.Name)
+	}
+	return names
+}
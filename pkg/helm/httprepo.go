@@ -0,0 +1,216 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	t "github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/krm-functions/catalog/pkg/version"
+	"sigs.k8s.io/yaml"
+)
+
+// IndexFile is the subset of a Helm repository 'index.yaml' used for searching and pulling charts
+type IndexFile struct {
+	APIVersion string                     `json:"apiVersion"`
+	Entries    map[string][]*ChartVersion `json:"entries"`
+}
+
+// ChartVersion is an entry in a Helm repository index
+type ChartVersion struct {
+	Name        string    `json:"name"`
+	Version     string    `json:"version"`
+	AppVersion  string    `json:"appVersion,omitempty"`
+	Description string    `json:"description,omitempty"`
+	URLs        []string  `json:"urls"`
+	Created     time.Time `json:"created,omitempty"`
+	Digest      string    `json:"digest,omitempty"`
+}
+
+// fetchIndex retrieves and parses the index of a HTTP Helm repository.
+// Indexes are cached for the index TTL of the chart cache
+func fetchIndex(repoURL, username, password string) (*IndexFile, error) {
+	indexURL := strings.TrimSuffix(repoURL, "/") + "/index.yaml"
+	b, found := chartCache.Index(indexURL)
+	if !found && chartCache.Offline() {
+		return nil, chartCache.missing("repo index %v", indexURL)
+	}
+	if !found {
+		var err error
+		b, err = httpGet(indexURL, username, password)
+		if err != nil {
+			return nil, fmt.Errorf("fetching repo index: %w", err)
+		}
+		if err = chartCache.PutIndex(indexURL, b); err != nil {
+			return nil, fmt.Errorf("caching repo index: %w", err)
+		}
+	}
+	index := &IndexFile{}
+	if err := yaml.Unmarshal(b, index); err != nil {
+		return nil, fmt.Errorf("parsing repo index %v: %w", indexURL, err)
+	}
+	if index.APIVersion == "" {
+		return nil, fmt.Errorf("parsing repo index %v: no API version specified", indexURL)
+	}
+	return index, nil
+}
+
+// searchHTTPRepo lists the versions of a chart in a HTTP Helm repository
+func searchHTTPRepo(chart *t.HelmChartArgs, username, password string) ([]RepoSearch, error) {
+	index, err := fetchIndex(chart.Repo, username, password)
+	if err != nil {
+		return nil, err
+	}
+	entries := index.Entries[chart.Name]
+	versions := make([]RepoSearch, len(entries))
+	for idx, e := range entries {
+		versions[idx] = RepoSearch{
+			Version:     e.Version,
+			AppVersion:  e.AppVersion,
+			Name:        e.Name,
+			Description: e.Description,
+			Created:     e.Created,
+		}
+	}
+	return versions, nil
+}
+
+// httpChartURL looks up a chart version in the index of a HTTP Helm
+// repository and returns the absolute URL of the chart tarball
+// together with the index entry
+func httpChartURL(chart *t.HelmChartArgs, username, password string) (string, *ChartVersion, error) {
+	index, err := fetchIndex(chart.Repo, username, password)
+	if err != nil {
+		return "", nil, err
+	}
+	var entry *ChartVersion
+	for _, e := range index.Entries[chart.Name] {
+		if e.Version == chart.Version {
+			entry = e
+			break
+		}
+	}
+	if entry == nil || len(entry.URLs) == 0 {
+		return "", nil, fmt.Errorf("chart %v version %v not found in repo %v", chart.Name, chart.Version, chart.Repo)
+	}
+	chartURL, err := resolveReferenceURL(chart.Repo, entry.URLs[0])
+	if err != nil {
+		return "", nil, err
+	}
+	return chartURL, entry, nil
+}
+
+// pullHTTPChart downloads a chart tarball from a HTTP Helm repository.
+// If the repository index records a digest for the chart, the tarball
+// is verified against it
+func pullHTTPChart(chart *t.HelmChartArgs, username, password string) ([]byte, error) {
+	chartURL, entry, err := httpChartURL(chart, username, password)
+	if err != nil {
+		return nil, err
+	}
+	b, err := httpGetFromRepo(chart.Repo, chartURL, username, password)
+	if err != nil {
+		return nil, fmt.Errorf("fetching chart: %w", err)
+	}
+	if entry.Digest != "" {
+		if sum := fmt.Sprintf("%x", sha256.Sum256(b)); sum != entry.Digest {
+			return nil, fmt.Errorf("chart %v digest mismatch, expected %v, got %v", chartURL, entry.Digest, sum)
+		}
+	}
+	return b, nil
+}
+
+// pullHTTPProvenance downloads the provenance file of a chart from a
+// HTTP Helm repository. The provenance file is located next to the
+// chart tarball
+func pullHTTPProvenance(chart *t.HelmChartArgs, username, password string) ([]byte, error) {
+	chartURL, _, err := httpChartURL(chart, username, password)
+	if err != nil {
+		return nil, err
+	}
+	b, err := httpGetFromRepo(chart.Repo, chartURL+".prov", username, password)
+	if err != nil {
+		return nil, fmt.Errorf("fetching provenance file: %w", err)
+	}
+	return b, nil
+}
+
+// httpGetFromRepo fetches an URL referenced from a repository index.
+// Credentials are only passed if the URL is on the repository host
+func httpGetFromRepo(repoURL, u, username, password string) ([]byte, error) {
+	if !sameHost(repoURL, u) {
+		username, password = "", ""
+	}
+	return httpGet(u, username, password)
+}
+
+func httpGet(u, username, password string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, u, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "krm-functions/"+version.Version)
+	if username != "" && password != "" {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %v: %v", u, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// resolveReferenceURL resolves a possibly relative chart URL from a repository index against the repository URL
+func resolveReferenceURL(baseURL, refURL string) (string, error) {
+	ref, err := url.Parse(refURL)
+	if err != nil {
+		return "", fmt.Errorf("parsing URL %v: %w", refURL, err)
+	}
+	if ref.IsAbs() {
+		return refURL, nil
+	}
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("parsing URL %v: %w", baseURL, err)
+	}
+	// A trailing slash is needed for ResolveReference to treat the base as a directory
+	base.RawPath = strings.TrimSuffix(base.RawPath, "/") + "/"
+	base.Path = strings.TrimSuffix(base.Path, "/") + "/"
+	resolved := base.ResolveReference(ref)
+	resolved.RawQuery = base.RawQuery
+	return resolved.String(), nil
+}
+
+func sameHost(u1, u2 string) bool {
+	p1, err := url.Parse(u1)
+	if err != nil {
+		return false
+	}
+	p2, err := url.Parse(u2)
+	if err != nil {
+		return false
+	}
+	return p1.Host == p2.Host
+}
@@ -0,0 +1,71 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"fmt"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/krm-functions/catalog/pkg/api"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/fake"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// packageClientProvider implements the Helm 'lookup' template function
+// with the objects of a package, i.e. without a cluster. Objects are
+// found with the namespace given in the package, thus objects without a
+// namespace are found with an empty namespace, as cluster-scoped objects
+type packageClientProvider struct {
+	client    dynamic.Interface
+	listKinds map[schema.GroupVersionResource]string
+}
+
+func newPackageClientProvider(objects fn.KubeObjects) (*packageClientProvider, error) {
+	listKinds := map[schema.GroupVersionResource]string{}
+	var objs []runtime.Object
+	for _, o := range objects {
+		// Chart specs hold embedded charts and are not cluster objects
+		if o.IsGVK(api.HelmResourceAPI, "", "RenderHelmChart") || o.IsGVK(api.KptResourceAPI, "", "RenderHelmChart") {
+			continue
+		}
+		u := &unstructured.Unstructured{}
+		if err := kyaml.Unmarshal([]byte(o.String()), &u.Object); err != nil {
+			return nil, fmt.Errorf("parsing %v %v: %w", o.GetKind(), o.GetName(), err)
+		}
+		stripOrchestratorAnnotations(u.Object)
+		gvr, _ := meta.UnsafeGuessKindToResource(u.GroupVersionKind())
+		listKinds[gvr] = u.GetKind() + "List"
+		objs = append(objs, u)
+	}
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, objs...)
+	return &packageClientProvider{client, listKinds}, nil
+}
+
+// GetClientFor returns a client for objects of a kind. All kinds are
+// reported as namespaced, such that the namespace of a lookup is used as given
+func (p *packageClientProvider) GetClientFor(apiVersion, kind string) (dynamic.NamespaceableResourceInterface, bool, error) {
+	gvr, _ := meta.UnsafeGuessKindToResource(schema.FromAPIVersionAndKind(apiVersion, kind))
+	if _, found := p.listKinds[gvr]; !found {
+		// Kinds not in the package, which must be registered for listing
+		empty := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: kind + "List"})
+		return empty.Resource(gvr), true, nil
+	}
+	return p.client.Resource(gvr), true, nil
+}
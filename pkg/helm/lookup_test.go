@@ -0,0 +1,90 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/stretchr/testify/assert"
+)
+
+const lookupTemplate = `{{- $secret := lookup "v1" "Secret" .Release.Namespace "creds" }}
+{{- $crd := lookup "apiextensions.k8s.io/v1" "CustomResourceDefinition" "" "foos.example.com" }}
+{{- $cms := lookup "v1" "ConfigMap" "" "" }}
+{{- $missing := lookup "v1" "Service" "default" "missing" }}
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: lookup
+data:
+  password: {{ if $secret }}{{ $secret.data.password | quote }}{{ else }}"generated"{{ end }}
+  crd: {{ if $crd }}"found"{{ else }}"missing"{{ end }}
+  configMaps: {{ if $cms }}{{ len $cms.items | quote }}{{ else }}"0"{{ end }}
+  service: {{ if $missing }}"found"{{ else }}"missing"{{ end }}
+  annotations: {{ if $secret }}{{ len $secret.metadata.annotations | quote }}{{ end }}
+`
+
+func TestTemplateLookup(t *testing.T) {
+	tarball := testChartFromFiles(t, "lookup", map[string]string{
+		"Chart.yaml":            "apiVersion: v2\nname: lookup\nversion: 0.1.0\n",
+		"templates/lookup.yaml": lookupTemplate,
+	})
+	var objects fn.KubeObjects
+	for _, y := range []string{
+		"apiVersion: v1\nkind: Secret\nmetadata:\n  name: creds\n  namespace: default\n  annotations:\n    internal.config.kubernetes.io/path: secret.yaml\n    team: db\ndata:\n  password: c2VjcmV0\n",
+		"apiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\nmetadata:\n  name: foos.example.com\n",
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n  namespace: default\n",
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n",
+	} {
+		o, err := fn.ParseKubeObject([]byte(y))
+		if err != nil {
+			t.Fatal(err)
+		}
+		objects = append(objects, o)
+	}
+
+	chart := &helmspecs.HelmChart{Options: helmspecs.HelmTemplateOptions{ReleaseName: "rel", Namespace: "default", Lookup: true}}
+	rendered, _, err := Template(chart, tarball, nil, objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	objs, err := ParseAsKubeObjects(rendered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _, _ := objs[0].NestedStringMap("data")
+	assert.Equal(t, map[string]string{
+		"password":    "c2VjcmV0",
+		"crd":         "found",
+		"configMaps":  "2",
+		"service":     "missing",
+		"annotations": "1",
+	}, data)
+
+	// Without lookup, objects are not found, as with 'helm template'
+	chart.Options.Lookup = false
+	rendered, _, err = Template(chart, tarball, nil, objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	objs, err = ParseAsKubeObjects(rendered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	password, _, _ := objs[0].NestedString("data", "password")
+	assert.Equal(t, "generated", password)
+}
@@ -0,0 +1,151 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	t "github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/krm-functions/catalog/pkg/version"
+)
+
+// Media types of Helm charts stored in OCI registries
+const (
+	ChartLayerMediaType       types.MediaType = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+	LegacyChartLayerMediaType types.MediaType = "application/tar+gzip"
+	ProvLayerMediaType        types.MediaType = "application/vnd.cncf.helm.chart.provenance.v1.prov"
+)
+
+func isOciRepo(chart *t.HelmChartArgs) bool {
+	return strings.HasPrefix(chart.Repo, "oci://")
+}
+
+// ociRepository returns the OCI repository holding a chart
+func ociRepository(chart *t.HelmChartArgs) (name.Repository, error) {
+	return name.NewRepository(strings.TrimSuffix(strings.TrimPrefix(chart.Repo, "oci://"), "/") + "/" + chart.Name)
+}
+
+// ociReference returns the OCI reference to a chart version. Helm
+// stores versions with build metadata using '_' instead of '+', which
+// is not allowed in tags
+func ociReference(chart *t.HelmChartArgs) (name.Reference, error) {
+	repo, err := ociRepository(chart)
+	if err != nil {
+		return nil, err
+	}
+	return repo.Tag(strings.ReplaceAll(chart.Version, "+", "_")), nil
+}
+
+func ociOptions(username, password string) []remote.Option {
+	opts := []remote.Option{remote.WithUserAgent("krm-functions/" + version.Version)}
+	if username != "" && password != "" {
+		opts = append(opts, remote.WithAuth(&authn.Basic{Username: username, Password: password}))
+	} else {
+		opts = append(opts, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	}
+	return opts
+}
+
+// searchOCIRepo lists the versions of a chart in an OCI registry. Tag
+// lists are cached for the index TTL of the chart cache
+func searchOCIRepo(chart *t.HelmChartArgs, username, password string) ([]RepoSearch, error) {
+	repo, err := ociRepository(chart)
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	if b, found := chartCache.Index(repo.String()); found {
+		tags = strings.Fields(string(b))
+	} else if chartCache.Offline() {
+		return nil, chartCache.missing("tags of %v", repo)
+	} else {
+		tags, err = remote.List(repo, ociOptions(username, password)...)
+		if err != nil {
+			return nil, fmt.Errorf("listing tags of %v: %w", repo, err)
+		}
+		if err = chartCache.PutIndex(repo.String(), []byte(strings.Join(tags, "\n"))); err != nil {
+			return nil, fmt.Errorf("caching tags of %v: %w", repo, err)
+		}
+	}
+	versions := make([]RepoSearch, len(tags))
+	for idx, tag := range tags {
+		versions[idx].Name = chart.Name
+		versions[idx].Version = strings.ReplaceAll(tag, "_", "+")
+	}
+	return versions, nil
+}
+
+// pullOCIChart retrieves the chart tarball layer of a chart stored in an OCI registry
+func pullOCIChart(chart *t.HelmChartArgs, username, password string) ([]byte, error) {
+	ref, err := ociReference(chart)
+	if err != nil {
+		return nil, err
+	}
+	img, err := remote.Image(ref, ociOptions(username, password)...)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %v: %w", ref, err)
+	}
+	return ociLayer(img, ChartLayerMediaType, LegacyChartLayerMediaType)
+}
+
+// pullOCIProvenance retrieves the provenance layer of a chart stored in an OCI registry
+func pullOCIProvenance(chart *t.HelmChartArgs, username, password string) ([]byte, error) {
+	ref, err := ociReference(chart)
+	if err != nil {
+		return nil, err
+	}
+	img, err := remote.Image(ref, ociOptions(username, password)...)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %v: %w", ref, err)
+	}
+	return ociLayer(img, ProvLayerMediaType)
+}
+
+// ociLayer returns the content of the first layer in an OCI artifact with one of the given media types
+func ociLayer(img v1.Image, mediaTypes ...types.MediaType) ([]byte, error) {
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	idx := slices.IndexFunc(manifest.Layers, func(desc v1.Descriptor) bool {
+		return slices.Contains(mediaTypes, desc.MediaType)
+	})
+	if idx < 0 {
+		return nil, fmt.Errorf("no layer with media type %v found", mediaTypes)
+	}
+	return ociLayerByDigest(img, manifest.Layers[idx].Digest)
+}
+
+// ociLayerByDigest returns the content of a layer in an OCI artifact
+func ociLayerByDigest(img v1.Image, digest v1.Hash) ([]byte, error) {
+	layer, err := img.LayerByDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
@@ -0,0 +1,153 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"bytes"
+	"fmt"
+
+	t "github.com/krm-functions/catalog/pkg/helmspecs"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/kustomize/api/filters/patchjson6902"
+	"sigs.k8s.io/kustomize/api/filters/patchstrategicmerge"
+	ktypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/resid"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// PostRender applies the post-render patches of a chart to rendered
+// chart manifests. Patches are applied in order, with the same
+// semantics as Kustomize 'patches', i.e. both strategic-merge and
+// JSON6902 patches are supported. Strategic-merge patches without a
+// target apply to the object with the kind and name of the patch
+func PostRender(chart *t.HelmChart, rendered []byte) ([]byte, error) {
+	if chart.PostRender == nil || len(chart.PostRender.Patches) == 0 {
+		return rendered, nil
+	}
+	nodes, err := ParseAsRNodes(rendered)
+	if err != nil {
+		return nil, err
+	}
+	for idx := range chart.PostRender.Patches {
+		nodes, err = applyPatch(&chart.PostRender.Patches[idx], nodes)
+		if err != nil {
+			return nil, fmt.Errorf("chart %v post-render patch %d: %w", chart.Args.Name, idx, err)
+		}
+	}
+	var out bytes.Buffer
+	if err = (&kio.ByteWriter{Writer: &out}).Write(nodes); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// applyPatch applies a strategic-merge or JSON6902 patch to the nodes matching the patch target
+func applyPatch(patch *ktypes.Patch, nodes []*kyaml.RNode) ([]*kyaml.RNode, error) {
+	if patch.Path != "" {
+		return nil, fmt.Errorf("patch 'path' is not supported, use 'patch'")
+	}
+	if patch.Patch == "" {
+		return nil, fmt.Errorf("patch is empty")
+	}
+	patchNode, err := kyaml.Parse(patch.Patch)
+	if err != nil {
+		return nil, fmt.Errorf("parsing patch: %w", err)
+	}
+
+	var filter kio.Filter
+	target := patch.Target
+	if patchNode.YNode().Kind == kyaml.SequenceNode {
+		if target == nil {
+			return nil, fmt.Errorf("JSON6902 patch requires a target")
+		}
+		filter = patchjson6902.Filter{Patch: patch.Patch}
+	} else {
+		if target == nil {
+			target = &ktypes.Selector{ResId: resid.ResId{
+				Gvk:       resid.GvkFromNode(patchNode),
+				Name:      patchNode.GetName(),
+				Namespace: patchNode.GetNamespace(),
+			}}
+		}
+		filter = patchstrategicmerge.Filter{Patch: patchNode}
+	}
+
+	sel, err := ktypes.NewSelectorRegex(target)
+	if err != nil {
+		return nil, fmt.Errorf("parsing target: %w", err)
+	}
+	var result []*kyaml.RNode
+	for _, node := range nodes {
+		matched, mErr := matchSelector(target, sel, node)
+		if mErr != nil {
+			return nil, mErr
+		}
+		if !matched {
+			result = append(result, node)
+			continue
+		}
+		// Deleted nodes, e.g. using '$patch: delete', are not returned.
+		// JSON6902 patches rebuild nodes, thus '# Source:' comments are restored
+		comment := headComment(node)
+		patched, fErr := filter.Filter([]*kyaml.RNode{node})
+		if fErr != nil {
+			return nil, fmt.Errorf("patching %v %v: %w", node.GetKind(), node.GetName(), fErr)
+		}
+		for _, p := range patched {
+			if comment != "" && headComment(p) == "" {
+				p.YNode().Content[0].HeadComment = comment
+			}
+		}
+		result = append(result, patched...)
+	}
+	return result, nil
+}
+
+// matchSelector returns true if a node matches a patch target. Group,
+// version, kind, name and namespace may be regular expressions
+func matchSelector(target *ktypes.Selector, sel *ktypes.SelectorRegex, node *kyaml.RNode) (bool, error) {
+	if !sel.MatchGvk(resid.GvkFromNode(node)) || !sel.MatchName(node.GetName()) || !sel.MatchNamespace(node.GetNamespace()) {
+		return false, nil
+	}
+	if target.LabelSelector != "" {
+		s, err := labels.Parse(target.LabelSelector)
+		if err != nil {
+			return false, fmt.Errorf("parsing label selector: %w", err)
+		}
+		if !s.Matches(labels.Set(node.GetLabels())) {
+			return false, nil
+		}
+	}
+	if target.AnnotationSelector != "" {
+		s, err := labels.Parse(target.AnnotationSelector)
+		if err != nil {
+			return false, fmt.Errorf("parsing annotation selector: %w", err)
+		}
+		if !s.Matches(labels.Set(node.GetAnnotations())) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// headComment returns the comment before the first field of a node, e.g. the '# Source:' comment of Helm
+func headComment(node *kyaml.RNode) string {
+	y := node.YNode()
+	if len(y.Content) == 0 {
+		return ""
+	}
+	return y.Content[0].HeadComment
+}
@@ -0,0 +1,128 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"testing"
+
+	"github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/stretchr/testify/assert"
+	ktypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/resid"
+)
+
+const postRenderInput = `# Source: test/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  labels:
+    app: app
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: app
+        image: app:1.0
+---
+# Source: test/templates/cm.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+  labels:
+    app: app
+data:
+  key: value
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: other
+data:
+  key: value
+`
+
+func TestPostRender(t *testing.T) {
+	chart := &helmspecs.HelmChart{
+		Args: helmspecs.HelmChartArgs{Name: "test"},
+		PostRender: &helmspecs.HelmPostRender{
+			Patches: []ktypes.Patch{
+				// Strategic-merge patch targeting the object named in the patch
+				{Patch: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        securityContext:
+          runAsNonRoot: true
+`},
+				// JSON6902 patch targeting objects by label
+				{
+					Patch:  `[{"op": "replace", "path": "/data/key", "value": "patched"}]`,
+					Target: &ktypes.Selector{ResId: resid.ResId{Gvk: resid.Gvk{Kind: "ConfigMap"}}, LabelSelector: "app=app"},
+				},
+				// Deleting objects
+				{Patch: `$patch: delete
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: other
+`},
+			},
+		},
+	}
+	out, err := PostRender(chart, []byte(postRenderInput))
+	assert.NoError(t, err)
+	objs, err := ParseAsRNodes(out)
+	assert.NoError(t, err)
+	assert.Len(t, objs, 2)
+
+	val, err := objs[0].GetString("spec.template.spec.containers.[name=app].image")
+	assert.NoError(t, err)
+	assert.Equal(t, "app:1.0", val)
+	nonRoot, err := objs[0].GetFieldValue("spec.template.spec.containers.[name=app].securityContext.runAsNonRoot")
+	assert.NoError(t, err)
+	assert.Equal(t, true, nonRoot)
+
+	val, err = objs[1].GetString("data.key")
+	assert.NoError(t, err)
+	assert.Equal(t, "patched", val)
+	assert.Contains(t, string(out), "# Source: test/templates/cm.yaml")
+}
+
+func TestPostRenderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch ktypes.Patch
+	}{
+		{"path", ktypes.Patch{Path: "patch.yaml"}},
+		{"empty", ktypes.Patch{}},
+		{"json6902 without target", ktypes.Patch{Patch: `[{"op": "remove", "path": "/data"}]`}},
+		{"invalid label selector", ktypes.Patch{Patch: "kind: ConfigMap\nmetadata:\n  name: other\n", Target: &ktypes.Selector{LabelSelector: "a in b"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chart := &helmspecs.HelmChart{PostRender: &helmspecs.HelmPostRender{Patches: []ktypes.Patch{tt.patch}}}
+			_, err := PostRender(chart, []byte(postRenderInput))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/krm-functions/catalog/pkg/api"
+	t "github.com/krm-functions/catalog/pkg/helmspecs"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const ClusterProfileKind = "ClusterProfile"
+
+// ApplyClusterProfile sets the Kubernetes version and API versions of
+// the cluster profile referenced by the template options of a chart.
+// The profile is looked up by name in objects. Template options take
+// precedence, i.e. the kubeVersion of a chart overrides the profile and
+// apiVersions of a chart are added to the API versions of the profile
+func ApplyClusterProfile(chart *t.HelmChart, objects fn.KubeObjects) error {
+	if chart.Options.ClusterProfile == "" {
+		return nil
+	}
+	profile, err := LookupClusterProfile(chart.Options.ClusterProfile, objects)
+	if err != nil {
+		return err
+	}
+	if chart.Options.KubeVersion == "" {
+		chart.Options.KubeVersion = profile.KubeVersion
+	}
+	apiVersions := slices.Clone(profile.APIVersions)
+	for _, v := range chart.Options.APIVersions {
+		if !slices.Contains(apiVersions, v) {
+			apiVersions = append(apiVersions, v)
+		}
+	}
+	chart.Options.APIVersions = apiVersions
+	return nil
+}
+
+// LookupClusterProfile returns the cluster profile with the given name
+func LookupClusterProfile(name string, objects fn.KubeObjects) (*t.ClusterProfile, error) {
+	for _, o := range objects {
+		if !o.IsGVK(api.HelmResourceAPI, "", ClusterProfileKind) || o.GetName() != name {
+			continue
+		}
+		profile := &t.ClusterProfile{}
+		if err := kyaml.Unmarshal([]byte(o.String()), profile); err != nil {
+			return nil, fmt.Errorf("parsing cluster profile %v: %w", name, err)
+		}
+		return profile, nil
+	}
+	return nil, fmt.Errorf("cluster profile %v not found", name)
+}
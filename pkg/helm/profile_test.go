@@ -0,0 +1,65 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/stretchr/testify/assert"
+)
+
+const testClusterProfile = `apiVersion: experimental.helm.sh/v1alpha1
+kind: ClusterProfile
+metadata:
+  name: prod
+kubeVersion: 1.30.0
+apiVersions:
+- monitoring.coreos.com/v1
+- cert-manager.io/v1
+`
+
+func TestApplyClusterProfile(t *testing.T) {
+	profile, err := fn.ParseKubeObject([]byte(testClusterProfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects := fn.KubeObjects{profile}
+
+	chart := &helmspecs.HelmChart{Options: helmspecs.HelmTemplateOptions{ClusterProfile: "prod", APIVersions: []string{"cert-manager.io/v1", "foo/v1"}}}
+	assert.NoError(t, ApplyClusterProfile(chart, objects))
+	assert.Equal(t, "1.30.0", chart.Options.KubeVersion)
+	assert.Equal(t, []string{"monitoring.coreos.com/v1", "cert-manager.io/v1", "foo/v1"}, chart.Options.APIVersions)
+
+	rendered, _, err := Template(chart, testChartTarball(t), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	objs, err := ParseAsKubeObjects(rendered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kubeVersion, _, _ := objs[0].NestedString("data", "kubeVersion")
+	assert.Equal(t, "v1.30.0", kubeVersion)
+
+	// Chart kubeVersion takes precedence
+	chart = &helmspecs.HelmChart{Options: helmspecs.HelmTemplateOptions{ClusterProfile: "prod", KubeVersion: "1.29.0"}}
+	assert.NoError(t, ApplyClusterProfile(chart, objects))
+	assert.Equal(t, "1.29.0", chart.Options.KubeVersion)
+
+	chart = &helmspecs.HelmChart{Options: helmspecs.HelmTemplateOptions{ClusterProfile: "staging"}}
+	assert.Error(t, ApplyClusterProfile(chart, objects))
+}
@@ -0,0 +1,138 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	t "github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/krm-functions/catalog/pkg/util"
+	"golang.org/x/crypto/openpgp" //nolint:staticcheck // Helm provenance files are verified with this package
+	"helm.sh/helm/v3/pkg/provenance"
+)
+
+// ErrVerificationFailed is returned when a chart cannot be verified,
+// e.g. due to a missing or invalid signature
+var ErrVerificationFailed = errors.New("chart verification failed")
+
+// VerifyChart verifies the provenance of a chart if the chart spec
+// references a keyring, which is looked up in the resource list.
+// Returns the identity of the signer, or an empty string if no keyring
+// is referenced, i.e. provenance is not verified
+func VerifyChart(chart *t.HelmChartArgs, chartData []byte, rl *fn.ResourceList, username, password string) (string, error) {
+	if chart.Keyring == nil {
+		return "", nil
+	}
+	keyring, err := util.LookupKeyringSecret(chart.Keyring.Name, chart.Keyring.Namespace, rl)
+	if err != nil {
+		return "", err
+	}
+	return VerifyProvenance(chart, chartData, keyring, username, password)
+}
+
+// FetchProvenance retrieves the provenance ('.prov') file of a chart
+// from the chart cache or the chart repository. For HTTP repositories
+// the provenance file is located next to the chart tarball, for OCI
+// registries it is a layer of the chart artifact
+func FetchProvenance(chart *t.HelmChartArgs, username, password string) ([]byte, error) {
+	if prov, found := chartCache.Provenance(chart); found {
+		return prov, nil
+	}
+	if chartCache.Offline() {
+		return nil, chartCache.missing("provenance file of chart %v version %v from %v", chart.Name, chart.Version, chart.Repo)
+	}
+	var prov []byte
+	var err error
+	if isOciRepo(chart) {
+		prov, err = pullOCIProvenance(chart, username, password)
+	} else {
+		prov, err = pullHTTPProvenance(chart, username, password)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = chartCache.PutProvenance(chart, prov); err != nil {
+		return nil, fmt.Errorf("caching provenance file: %w", err)
+	}
+	return prov, nil
+}
+
+// VerifyProvenance fetches the provenance file of a chart, verifies
+// its PGP signature using the public keys in keyring, and that the
+// signed sha256 digest matches the chart tarball. The keyring may be
+// binary or ASCII armored. Returns the identity of the signer
+func VerifyProvenance(chart *t.HelmChartArgs, chartData, keyring []byte, username, password string) (string, error) {
+	prov, err := FetchProvenance(chart, username, password)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrVerificationFailed, err)
+	}
+	signer, err := verifyProvenance(chart, chartData, prov, keyring)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrVerificationFailed, err)
+	}
+	return signer, nil
+}
+
+func verifyProvenance(chart *t.HelmChartArgs, chartData, prov, keyring []byte) (string, error) {
+	var keys openpgp.EntityList
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(keyring), []byte("-----BEGIN")) {
+		keys, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(keyring))
+	} else {
+		keys, err = openpgp.ReadKeyRing(bytes.NewReader(keyring))
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading keyring: %w", err)
+	}
+
+	// Helm verifies files, with the tarball name as recorded in the provenance file
+	tmpDir, err := os.MkdirTemp("", "chart-prov-")
+	if err != nil {
+		return "", fmt.Errorf("creating tmp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	chartFile := filepath.Join(tmpDir, chartTarballName(chart))
+	if err := os.WriteFile(chartFile, chartData, 0o600); err != nil {
+		return "", err
+	}
+	provFile := chartFile + ".prov"
+	if err := os.WriteFile(provFile, prov, 0o600); err != nil {
+		return "", err
+	}
+
+	sig := &provenance.Signatory{KeyRing: keys}
+	ver, err := sig.Verify(chartFile, provFile)
+	if err != nil {
+		return "", fmt.Errorf("verifying chart %v: %w", chart.Name, err)
+	}
+	return signerIdentity(ver.SignedBy), nil
+}
+
+// signerIdentity formats the identities and key fingerprint of a signing entity
+func signerIdentity(e *openpgp.Entity) string {
+	names := make([]string, 0, len(e.Identities))
+	for name := range e.Identities {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return fmt.Sprintf("%s (%X)", strings.Join(names, ", "), e.PrimaryKey.Fingerprint)
+}
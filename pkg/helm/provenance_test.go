@@ -0,0 +1,80 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/openpgp" //nolint:staticcheck // Helm provenance files are signed with this package
+	"golang.org/x/crypto/openpgp/armor"
+	"helm.sh/helm/v3/pkg/provenance"
+)
+
+// testSignChart signs a chart tarball and returns the provenance file and the armored public keyring
+func testSignChart(t *testing.T, tarball []byte) (prov, keyring []byte) {
+	t.Helper()
+	entity, err := openpgp.NewEntity("Test Signer", "", "signer@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chartFile := filepath.Join(t.TempDir(), "test-chart-0.1.0.tgz")
+	if err := os.WriteFile(chartFile, tarball, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sig := &provenance.Signatory{Entity: entity}
+	signed, err := sig.ClearSign(chartFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return []byte(signed), buf.Bytes()
+}
+
+func TestVerifyProvenance(t *testing.T) {
+	tarball := testChartTarball(t)
+	chart := &helmspecs.HelmChartArgs{Name: "test-chart", Version: "0.1.0"}
+	prov, keyring := testSignChart(t, tarball)
+
+	signer, err := verifyProvenance(chart, tarball, prov, keyring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, strings.HasPrefix(signer, "Test Signer <signer@example.com> ("), signer)
+
+	tampered := append(bytes.Clone(tarball), 0)
+	_, err = verifyProvenance(chart, tampered, prov, keyring)
+	assert.Error(t, err)
+
+	_, otherKeyring := testSignChart(t, tarball)
+	_, err = verifyProvenance(chart, tarball, prov, otherKeyring)
+	assert.Error(t, err)
+}
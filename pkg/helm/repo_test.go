@@ -0,0 +1,146 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/stretchr/testify/assert"
+)
+
+const testChartConfigMediaType = "application/vnd.cncf.helm.config.v1+json"
+
+// newTestHTTPRepo starts a HTTP Helm repository serving the test chart
+func newTestHTTPRepo(t *testing.T, tarball []byte) *httptest.Server {
+	t.Helper()
+	index := fmt.Sprintf(`apiVersion: v1
+entries:
+  test-chart:
+  - name: test-chart
+    version: 0.1.0
+    appVersion: 1.16.0
+    urls:
+    - charts/test-chart-0.1.0.tgz
+    digest: %x
+  - name: test-chart
+    version: 0.2.0
+    appVersion: 1.17.0
+    urls:
+    - charts/test-chart-0.2.0.tgz
+`, sha256.Sum256(tarball))
+	mux := http.NewServeMux()
+	mux.HandleFunc("/index.yaml", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(index))
+	})
+	mux.HandleFunc("/charts/test-chart-0.1.0.tgz", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(tarball)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newTestOCIRepo starts an OCI registry holding the test chart and returns the 'oci://' repo URL
+func newTestOCIRepo(t *testing.T, tarball []byte) string {
+	t.Helper()
+	srv := httptest.NewServer(registry.New())
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := mutate.Append(empty.Image, mutate.Addendum{Layer: static.NewLayer(tarball, ChartLayerMediaType)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img = mutate.ConfigMediaType(img, testChartConfigMediaType)
+	for _, tag := range []string{"0.1.0", "0.2.0_build.1"} {
+		ref, err := name.ParseReference(u.Host + "/charts/test-chart:" + tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(ref, img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return "oci://" + u.Host + "/charts"
+}
+
+func TestHTTPRepo(t *testing.T) {
+	tarball := testChartTarball(t)
+	srv := newTestHTTPRepo(t, tarball)
+	chart := &helmspecs.HelmChartArgs{Name: "test-chart", Version: "0.1.0", Repo: srv.URL}
+
+	search, err := SearchRepo(chart, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"0.1.0", "0.2.0"}, ToList(search))
+	s, err := GetSearch(search, "0.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "1.16.0", s.AppVersion)
+
+	data, tarballName, sum, err := SourceChart(chart, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, tarball, data)
+	assert.Equal(t, "", tarballName)
+	assert.Equal(t, fmt.Sprintf("%x", sha256.Sum256(tarball)), sum)
+
+	tarballName, _, err = PullChart(chart, t.TempDir(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "test-chart-0.1.0.tgz", tarballName)
+
+	missing := *chart
+	missing.Version = "0.3.0"
+	_, _, _, err = SourceChart(&missing, "", "", "")
+	assert.Error(t, err)
+}
+
+func TestOCIRepo(t *testing.T) {
+	tarball := testChartTarball(t)
+	repo := newTestOCIRepo(t, tarball)
+	chart := &helmspecs.HelmChartArgs{Name: "test-chart", Version: "0.1.0", Repo: repo}
+
+	search, err := SearchRepo(chart, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"0.1.0", "0.2.0+build.1"}, ToList(search))
+
+	data, _, sum, err := SourceChart(chart, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, tarball, data)
+	assert.Equal(t, fmt.Sprintf("%x", sha256.Sum256(tarball)), sum)
+}
@@ -0,0 +1,99 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	t "github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/xeipuuv/gojsonschema"
+	helmchart "helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// ValuesError is a chart value violating the values schema of a chart
+type ValuesError struct {
+	// Path of the value, e.g. 'image.tag'. Values of subcharts are prefixed with the subchart name
+	Field   string
+	Message string
+}
+
+// ValidateValues validates the values of a chart, i.e. the
+// user-supplied values merged with the chart defaults, against the
+// values schemas ('values.schema.json') of the chart and its
+// subcharts. Returns the violations, ordered by field path
+func ValidateValues(chart *t.HelmChart, chartTarball []byte, pkgValuesFiles map[string][]byte) ([]ValuesError, error) {
+	chrt, vals, err := loadChart(chart, chartTarball, pkgValuesFiles)
+	if err != nil {
+		return nil, err
+	}
+	coalesced, err := chartutil.CoalesceValues(chrt, vals)
+	if err != nil {
+		return nil, err
+	}
+	verrs, err := validateValues(chrt, coalesced, "")
+	if err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(verrs, func(a, b ValuesError) int {
+		return cmp.Or(cmp.Compare(a.Field, b.Field), cmp.Compare(a.Message, b.Message))
+	})
+	return verrs, nil
+}
+
+// validateValues validates values against the schema of a chart and,
+// recursively, the values of subcharts against their schemas
+func validateValues(chrt *helmchart.Chart, values map[string]any, prefix string) ([]ValuesError, error) {
+	if values == nil {
+		values = map[string]any{}
+	}
+	var verrs []ValuesError
+	if chrt.Schema != nil {
+		valuesJSON, err := json.Marshal(values)
+		if err != nil {
+			return nil, err
+		}
+		result, err := gojsonschema.Validate(gojsonschema.NewBytesLoader(chrt.Schema), gojsonschema.NewBytesLoader(valuesJSON))
+		if err != nil {
+			return nil, fmt.Errorf("validating values of chart %v: %w", chrt.Name(), err)
+		}
+		for _, e := range result.Errors() {
+			field := e.Field()
+			if field == gojsonschema.STRING_ROOT_SCHEMA_PROPERTY {
+				field = ""
+			}
+			verrs = append(verrs, ValuesError{Field: joinField(prefix, field), Message: e.Description()})
+		}
+	}
+	for _, sub := range chrt.Dependencies() {
+		subValues, _ := values[sub.Name()].(map[string]any)
+		subErrs, err := validateValues(sub, subValues, joinField(prefix, sub.Name()))
+		if err != nil {
+			return nil, err
+		}
+		verrs = append(verrs, subErrs...)
+	}
+	return verrs, nil
+}
+
+func joinField(prefix, field string) string {
+	if prefix == "" || field == "" {
+		return prefix + field
+	}
+	return prefix + "." + field
+}
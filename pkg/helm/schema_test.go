@@ -0,0 +1,80 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"testing"
+
+	"github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/stretchr/testify/assert"
+)
+
+const testValuesSchema = `{
+  "type": "object",
+  "required": ["image"],
+  "properties": {
+    "replicaCount": {"type": "integer", "minimum": 1},
+    "image": {
+      "type": "object",
+      "required": ["repository"],
+      "properties": {"repository": {"type": "string"}, "tag": {"type": "string"}}
+    }
+  }
+}`
+
+func testSchemaChartTarball(t *testing.T) []byte {
+	t.Helper()
+	return testChartFromFiles(t, "schema", map[string]string{
+		"Chart.yaml":                    "apiVersion: v2\nname: schema\nversion: 0.1.0\n",
+		"values.yaml":                   "replicaCount: 1\nimage:\n  repository: nginx\n",
+		"values.schema.json":            testValuesSchema,
+		"templates/cm.yaml":             "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: schema\n",
+		"charts/sub/Chart.yaml":         "apiVersion: v2\nname: sub\nversion: 0.1.0\n",
+		"charts/sub/values.schema.json": `{"type": "object", "properties": {"enabled": {"type": "boolean"}}}`,
+		"charts/sub/templates/cm.yaml":  "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: sub\n",
+	})
+}
+
+func TestValidateValues(t *testing.T) {
+	tarball := testSchemaChartTarball(t)
+	chart := &helmspecs.HelmChart{Options: helmspecs.HelmTemplateOptions{ReleaseName: "rel"}}
+	verrs, err := ValidateValues(chart, tarball, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, verrs)
+
+	chart.Options.Values.ValuesInline = map[string]any{
+		"replicaCount": 0,
+		"image":        map[string]any{"tag": 1},
+		"sub":          map[string]any{"enabled": "yes"},
+	}
+	verrs, err = ValidateValues(chart, tarball, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"image.tag", "replicaCount", "sub.enabled"}, valuesErrorFields(verrs))
+
+	// Values from files are validated too. A null value removes the key, i.e. a required value is missing
+	chart.Options.Values = helmspecs.HelmValues{ValuesFiles: []string{"prod.yaml"}}
+	verrs, err = ValidateValues(chart, tarball, map[string][]byte{"prod.yaml": []byte("image: null\n")})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{""}, valuesErrorFields(verrs))
+	assert.Contains(t, verrs[0].Message, "image is required")
+}
+
+func valuesErrorFields(verrs []ValuesError) []string {
+	var fields []string
+	for _, ve := range verrs {
+		fields = append(fields, ve.Field)
+	}
+	return fields
+}
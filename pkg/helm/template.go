@@ -24,51 +24,142 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
+	"text/template"
 
 	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/Masterminds/sprig/v3"
 	securejoin "github.com/cyphar/filepath-securejoin"
 	t "github.com/krm-functions/catalog/pkg/helmspecs"
+	helmchart "helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
 	"sigs.k8s.io/kustomize/kyaml/kio"
 	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
 const (
 	maxChartTemplateFileLength = 1024 * 2048
+	notesFileSuffix            = "NOTES.txt"
+	defaultReleaseName         = "release-name"
+	defaultNamespace           = "default"
 )
 
-// Template extracts a chart tarball and renders the chart using given
-// values and `helm template`. The raw chart tarball data is given in
-// `chartTarball` (note, not base64 encoded). Returns the rendered
-// text
-func Template(chart *t.HelmChart, chartTarball []byte) ([]byte, error) {
-	tmpDir, err := os.MkdirTemp("", "chart-")
+// Template renders a chart tarball in-process using given values,
+// similar to `helm template`. The raw chart tarball data is given in
+// `chartTarball` (note, not base64 encoded). Values files from the
+// package are given in `pkgValuesFiles`, see PackageValuesFiles. With
+// the `lookup` template option, the Helm 'lookup' function finds
+// objects among `pkgObjects`. Returns the rendered text and warnings
+// of hooks not translated exactly, see renderHooks. Template failures
+// are returned as a *TemplateError
+func Template(chart *t.HelmChart, chartTarball []byte, pkgValuesFiles map[string][]byte, pkgObjects fn.KubeObjects) ([]byte, []string, error) {
+	chrt, vals, err := loadChart(chart, chartTarball, pkgValuesFiles)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	defer os.RemoveAll(tmpDir)
 
-	err = ExtractChart(chartTarball, tmpDir)
+	caps, err := capabilities(&chart.Options)
 	if err != nil {
-		return nil, fmt.Errorf("extracting chart: %w", err)
+		return nil, nil, err
+	}
+	if chrt.Metadata.KubeVersion != "" && !chartutil.IsCompatibleRange(chrt.Metadata.KubeVersion, caps.KubeVersion.String()) {
+		return nil, nil, fmt.Errorf("chart requires kubeVersion: %s which is incompatible with Kubernetes %s", chrt.Metadata.KubeVersion, caps.KubeVersion.String())
 	}
 
-	valuesFile := filepath.Join(tmpDir, "values.yaml")
-	err = writeValuesFile(chart, valuesFile)
+	releaseName, err := releaseName(&chart.Options)
 	if err != nil {
-		return nil, fmt.Errorf("writing values file: %w", err)
+		return nil, nil, err
+	}
+	// Defaults as with 'helm template'. The release description,
+	// i.e. 'description', is not available to templates and does not
+	// affect the rendered manifests
+	namespace := chart.Options.Namespace
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	options := chartutil.ReleaseOptions{
+		Name:      releaseName,
+		Namespace: namespace,
+		Revision:  1,
+		IsInstall: true,
+	}
+	valuesToRender, err := chartutil.ToRenderValues(chrt, vals, options, caps)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var files map[string]string
+	if chart.Options.Lookup {
+		clientProvider, cpErr := newPackageClientProvider(pkgObjects)
+		if cpErr != nil {
+			return nil, nil, cpErr
+		}
+		files, err = engine.RenderWithClientProvider(chrt, valuesToRender, clientProvider)
+	} else {
+		var e engine.Engine
+		files, err = e.Render(chrt, valuesToRender)
+	}
+	if err != nil {
+		return nil, nil, templateError(err)
+	}
+	for k := range files {
+		if strings.HasSuffix(k, notesFileSuffix) {
+			delete(files, k)
+		}
 	}
-	args := buildHelmTemplateArgs(chart)
-	args = append(args, "--values", valuesFile, filepath.Join(tmpDir, chart.Args.Name))
 
-	helmCtxt := NewRunContext()
-	defer helmCtxt.DiscardContext()
-	stdout, err := helmCtxt.Run(args...)
+	hooks, manifests, err := releaseutil.SortManifests(files, caps.APIVersions, releaseutil.InstallOrder)
 	if err != nil {
-		return nil, fmt.Errorf("running helm template: %w", err)
+		return nil, nil, fmt.Errorf("parsing rendered manifests: %w", err)
 	}
 
-	return stdout, nil
+	b := new(bytes.Buffer)
+	if chart.Options.IncludeCRDs {
+		for _, crd := range chrt.CRDObjects() {
+			fmt.Fprintf(b, "---\n# Source: %s\n%s\n", crd.Filename, string(crd.File.Data))
+		}
+	}
+	for _, m := range manifests {
+		fmt.Fprintf(b, "---\n# Source: %s\n%s\n", m.Name, m.Content)
+	}
+	hooks, warnings, err := renderHooks(hooks, &chart.Options)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, h := range hooks {
+		fmt.Fprintf(b, "---\n# Source: %s\n%s\n", h.Path, h.Manifest)
+	}
+
+	return b.Bytes(), warnings, nil
+}
+
+// loadChart loads a chart tarball and returns the chart with the
+// user-supplied values, with chart dependencies processed as by Helm
+func loadChart(chart *t.HelmChart, chartTarball []byte, pkgValuesFiles map[string][]byte) (*helmchart.Chart, map[string]any, error) {
+	chrt, err := loader.LoadArchive(bytes.NewReader(chartTarball))
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading chart: %w", err)
+	}
+	if chrt.Metadata.Type != "" && chrt.Metadata.Type != "application" {
+		return nil, nil, fmt.Errorf("%s charts are not installable", chrt.Metadata.Type)
+	}
+	if err = checkDependencies(chrt); err != nil {
+		return nil, nil, err
+	}
+
+	vals, err := chartValues(chart, chrt, pkgValuesFiles)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing values: %w", err)
+	}
+	if err = chartutil.ProcessDependenciesWithMerge(chrt, vals); err != nil {
+		return nil, nil, fmt.Errorf("processing chart dependencies: %w", err)
+	}
+	return chrt, vals, nil
 }
 
 // ExtractChart extracts a chart tarball into destDir
@@ -152,43 +243,71 @@ func ParseAsRNodes(rendered []byte) ([]*kyaml.RNode, error) {
 	return nodes, nil
 }
 
-// writeValuesFile writes chart values to a file for passing to Helm
-func writeValuesFile(chart *t.HelmChart, valuesFilename string) error {
-	vals := chart.Options.Values.ValuesInline
-	b, err := kyaml.Marshal(vals)
-	if err != nil {
-		return err
+// TemplateSource returns the chart template path of a rendered object,
+// i.e. the path Helm records in a '# Source:' comment before each object
+func TemplateSource(node *kyaml.RNode) string {
+	for _, line := range strings.Split(headComment(node), "\n") {
+		if src, found := strings.CutPrefix(line, "# Source: "); found {
+			return strings.TrimSpace(src)
+		}
 	}
-	return os.WriteFile(valuesFilename, b, 0o600)
+	return ""
 }
 
-// buildHelmTemplateArgs prepares arguments for `helm template`
-func buildHelmTemplateArgs(chart *t.HelmChart) []string {
-	opts := chart.Options
-	args := []string{"template"}
-	if opts.ReleaseName != "" {
-		args = append(args, opts.ReleaseName)
-	}
-	if opts.Namespace != "" {
-		args = append(args, "--namespace", opts.Namespace)
+// capabilities builds the simulated cluster capabilities used when
+// rendering, i.e. what `--kube-version` and `--api-versions` set for
+// `helm template`
+func capabilities(opts *t.HelmTemplateOptions) (*chartutil.Capabilities, error) {
+	caps := chartutil.DefaultCapabilities.Copy()
+	if opts.KubeVersion != "" {
+		kubeVersion, err := chartutil.ParseKubeVersion(opts.KubeVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kube version %q: %w", opts.KubeVersion, err)
+		}
+		caps.KubeVersion = *kubeVersion
 	}
-	if opts.NameTemplate != "" {
-		args = append(args, "--name-template", opts.NameTemplate)
+	caps.APIVersions = append(caps.APIVersions, opts.APIVersions...)
+	return caps, nil
+}
+
+// releaseName returns the release name, possibly generated from the
+// name template. Defaults to 'release-name' as with 'helm template'
+func releaseName(opts *t.HelmTemplateOptions) (string, error) {
+	if opts.NameTemplate == "" {
+		if opts.ReleaseName == "" {
+			return defaultReleaseName, nil
+		}
+		return opts.ReleaseName, nil
 	}
-	for _, apiVer := range opts.APIVersions {
-		args = append(args, "--api-versions", apiVer)
+	if opts.ReleaseName != "" {
+		return "", fmt.Errorf("cannot use both releaseName and nameTemplate")
 	}
-	if opts.KubeVersion != "" {
-		args = append(args, "--kube-version", opts.KubeVersion)
+	tmpl, err := template.New("name-template").Funcs(sprig.TxtFuncMap()).Parse(opts.NameTemplate)
+	if err != nil {
+		return "", fmt.Errorf("parsing name template: %w", err)
 	}
-	if opts.Description != "" {
-		args = append(args, "--description", opts.Description)
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, nil); err != nil {
+		return "", fmt.Errorf("executing name template: %w", err)
 	}
-	if opts.IncludeCRDs {
-		args = append(args, "--include-crds")
+	return b.String(), nil
+}
+
+// checkDependencies verifies that all dependencies declared in
+// Chart.yaml are available as sub-charts
+func checkDependencies(chrt *helmchart.Chart) error {
+	var missing []string
+	for _, req := range chrt.Metadata.Dependencies {
+		if !slices.ContainsFunc(chrt.Dependencies(), func(d *helmchart.Chart) bool { return d.Name() == req.Name }) {
+			missing = append(missing, req.Name)
+		}
 	}
-	if opts.SkipTests {
-		args = append(args, "--skip-tests")
+	if len(missing) > 0 {
+		return fmt.Errorf("found in Chart.yaml, but missing in charts/ directory: %s", strings.Join(missing, ", "))
 	}
-	return args
+	return nil
+}
+
+func isTestHook(h *release.Hook) bool {
+	return slices.Contains(h.Events, release.HookTest)
 }
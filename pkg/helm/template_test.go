@@ -0,0 +1,137 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"os"
+	"testing"
+
+	"github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/stretchr/testify/assert"
+	helmchart "helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+const testChartDir = "../../test-data/test-chart"
+
+// testChartTarball packages the test chart and returns the tarball bytes
+func testChartTarball(t *testing.T) []byte {
+	t.Helper()
+	chrt, err := loader.LoadDir(testChartDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fname, err := chartutil.Save(chrt, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestTemplate(t *testing.T) {
+	chart := &helmspecs.HelmChart{
+		Args: helmspecs.HelmChartArgs{Name: "test-chart", Version: "0.1.0", Repo: "local"},
+		Options: helmspecs.HelmTemplateOptions{
+			ReleaseName: "test-chart-rel",
+			Namespace:   "default",
+			KubeVersion: "1.29.0",
+		},
+	}
+	rendered, _, err := Template(chart, testChartTarball(t), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	objs, err := ParseAsKubeObjects(rendered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, len(objs))
+	assert.Equal(t, "ConfigMap", objs[0].GetKind())
+	assert.Equal(t, "foo", objs[0].GetName())
+	kubeVersion, _, _ := objs[0].NestedString("data", "kubeVersion")
+	assert.Equal(t, "v1.29.0", kubeVersion)
+	kubeVersionMinor, _, _ := objs[0].NestedString("data", "kubeVersionMinor")
+	assert.Equal(t, "29", kubeVersionMinor)
+}
+
+func TestTemplateDefaults(t *testing.T) {
+	chrt := &helmchart.Chart{
+		Metadata: &helmchart.Metadata{APIVersion: helmchart.APIVersionV2, Name: "test-chart", Version: "0.1.0"},
+		Templates: []*helmchart.File{{Name: "templates/cm.yaml", Data: []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}
+  namespace: {{ .Release.Namespace }}
+`)}},
+	}
+	fname, err := chartutil.Save(chrt, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tarball, err := os.ReadFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Neither release name nor namespace given
+	rendered, _, err := Template(&helmspecs.HelmChart{}, tarball, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	objs, err := ParseAsKubeObjects(rendered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, len(objs))
+	assert.Equal(t, "release-name", objs[0].GetName())
+	assert.Equal(t, "default", objs[0].GetNamespace())
+}
+
+func TestTemplateInvalidKubeVersion(t *testing.T) {
+	chart := &helmspecs.HelmChart{
+		Options: helmspecs.HelmTemplateOptions{
+			ReleaseName: "test-chart-rel",
+			KubeVersion: "not-a-version",
+		},
+	}
+	_, _, err := Template(chart, testChartTarball(t), nil, nil)
+	assert.Error(t, err)
+}
+
+func TestTemplateSource(t *testing.T) {
+	chart := &helmspecs.HelmChart{
+		Options: helmspecs.HelmTemplateOptions{ReleaseName: "test-chart-rel"},
+	}
+	rendered, _, err := Template(chart, testChartTarball(t), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := ParseAsRNodes(rendered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, len(nodes))
+	assert.Equal(t, "test-chart/templates/configmap.yaml", TemplateSource(nodes[0]))
+
+	nodes, err = ParseAsRNodes([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: foo\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "", TemplateSource(nodes[0]))
+}
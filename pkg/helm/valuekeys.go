@@ -0,0 +1,152 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// RemovedValues returns the key paths of values, e.g. 'image.tag',
+// that are known by the chart in fromChart but not by the chart in
+// toChart, i.e. values that silently stop having an effect when
+// upgrading. Keys are known if found in the default values of the
+// chart or its subcharts, or declared in the values schema of the
+// chart. Keys below free-form values, e.g. empty maps in the default
+// values, are always known. Paths are returned in sorted order
+func RemovedValues(fromChart, toChart []byte, values map[string]any) ([]string, error) {
+	from, err := unknownValues(fromChart, values)
+	if err != nil {
+		return nil, err
+	}
+	to, err := unknownValues(toChart, values)
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, p := range to {
+		if !slices.ContainsFunc(from, func(f string) bool { return p == f || strings.HasPrefix(p, f+".") }) {
+			removed = append(removed, p)
+		}
+	}
+	return removed, nil
+}
+
+// unknownValues returns the topmost key paths of values not known by a chart
+func unknownValues(chartTarball []byte, values map[string]any) ([]string, error) {
+	chrt, err := loader.LoadArchive(bytes.NewReader(chartTarball))
+	if err != nil {
+		return nil, fmt.Errorf("loading chart: %w", err)
+	}
+	defaults, err := chartutil.CoalesceValues(chrt, nil)
+	if err != nil {
+		return nil, err
+	}
+	root := valuesNode{defaults: map[string]any(defaults), hasDefaults: true}
+	if len(chrt.Schema) > 0 {
+		if err = json.Unmarshal(chrt.Schema, &root.schema); err != nil {
+			return nil, fmt.Errorf("parsing values schema of chart %v: %w", chrt.Name(), err)
+		}
+	}
+	var unknown []string
+	for _, k := range slices.Sorted(maps.Keys(values)) {
+		if k == chartutil.GlobalKey {
+			continue
+		}
+		unknown = root.unknown(k, k, values[k], unknown)
+	}
+	return unknown, nil
+}
+
+// valuesNode is a key of the default values and values schema of a chart
+type valuesNode struct {
+	defaults    any
+	hasDefaults bool
+	schema      map[string]any
+}
+
+// unknown appends the paths of the key of the node, given by path,
+// and keys below it not known by the node
+func (n valuesNode) unknown(path, key string, value any, unknown []string) []string {
+	child, known := n.child(key)
+	if !known {
+		return append(unknown, path)
+	}
+	m, ok := value.(map[string]any)
+	if !ok || child.freeForm() {
+		return unknown
+	}
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		unknown = child.unknown(path+"."+k, k, m[k], unknown)
+	}
+	return unknown
+}
+
+// child returns the node of a key and whether the key is known
+func (n valuesNode) child(key string) (valuesNode, bool) {
+	var c valuesNode
+	known := false
+	if m, ok := n.defaults.(map[string]any); ok && n.hasDefaults {
+		if v, found := m[key]; found {
+			c.defaults, c.hasDefaults, known = v, true, true
+		}
+	}
+	if props, ok := n.schema["properties"].(map[string]any); ok {
+		if s, found := props[key].(map[string]any); found {
+			c.schema, known = s, true
+			return c, known
+		}
+	}
+	if patterns, ok := n.schema["patternProperties"].(map[string]any); ok {
+		for pattern, s := range patterns {
+			if matched, err := regexp.MatchString(pattern, key); err == nil && matched {
+				c.schema, _ = s.(map[string]any)
+				known = true
+				break
+			}
+		}
+	}
+	return c, known
+}
+
+// freeForm returns true if any key below the node is known, i.e. the
+// default value is not a map with keys or the schema allows any keys
+func (n valuesNode) freeForm() bool {
+	if n.hasDefaults {
+		if m, ok := n.defaults.(map[string]any); !ok || len(m) == 0 {
+			return true
+		}
+	}
+	for _, k := range []string{"$ref", "allOf", "anyOf", "oneOf"} {
+		if _, found := n.schema[k]; found {
+			return true
+		}
+	}
+	switch additional := n.schema["additionalProperties"].(type) {
+	case bool:
+		return additional
+	case map[string]any:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testRemovedValuesFrom = `image:
+  repository: app
+  tag: v1
+podAnnotations: {}
+resources:
+  limits:
+    cpu: 100m
+service:
+  port: 80
+`
+
+// Renames 'image.repository' to 'image.registry', drops 'service' and
+// declares 'extraEnv' in the schema only
+const testRemovedValuesTo = `image:
+  registry: app
+  tag: v2
+podAnnotations: {}
+resources:
+  limits:
+    cpu: 100m
+`
+
+const testRemovedValuesSchema = `{
+  "type": "object",
+  "properties": {
+    "extraEnv": {"type": "object", "additionalProperties": {"type": "string"}},
+    "image": {"type": "object"}
+  }
+}`
+
+func TestRemovedValues(t *testing.T) {
+	from := testValuesChart(t, "0.1.0", testRemovedValuesFrom, "")
+	to := testValuesChart(t, "0.2.0", testRemovedValuesTo, testRemovedValuesSchema)
+
+	values := map[string]any{
+		"global":         map[string]any{"any": "value"},
+		"image":          map[string]any{"repository": "mirror/app", "tag": "v1"},
+		"podAnnotations": map[string]any{"prometheus.io/scrape": "true"},
+		"resources":      map[string]any{"limits": map[string]any{"memory": "64Mi"}},
+		"service":        map[string]any{"port": 8080},
+		"extraEnv":       map[string]any{"FOO": "bar"},
+		"neverKnown":     true,
+	}
+	removed, err := RemovedValues(from, to, values)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"image.repository", "service"}, removed)
+
+	removed, err = RemovedValues(from, from, values)
+	assert.NoError(t, err)
+	assert.Empty(t, removed)
+
+	_, err = RemovedValues(from, []byte("not a chart"), values)
+	assert.Error(t, err)
+}
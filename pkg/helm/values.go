@@ -0,0 +1,195 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"path"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	t "github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/pmezard/go-difflib/difflib"
+	helmchart "helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// PackageValuesFiles looks up the values files referenced by a chart
+// among the objects of a package. Values file paths are relative to
+// the directory of the object holding the chart spec, whose path is
+// given by `specPath`. Returns the found values files keyed by their
+// name as given in `valuesFiles`. Values files not found in the
+// package are expected to be found in the chart itself
+func PackageValuesFiles(chart *t.HelmChart, specPath string, objects fn.KubeObjects) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	for _, name := range chart.Options.Values.ValuesFiles {
+		filePath := path.Join(path.Dir(specPath), name)
+		for _, o := range objects {
+			if path.Clean(o.PathAnnotation()) != filePath {
+				continue
+			}
+			b, err := valuesFromObject(o)
+			if err != nil {
+				return nil, fmt.Errorf("reading values file %q: %w", name, err)
+			}
+			files[name] = b
+			break
+		}
+	}
+	return files, nil
+}
+
+// valuesFromObject returns the YAML of an object read from a values
+// file, stripped of the annotations added by the orchestrator
+func valuesFromObject(o *fn.KubeObject) ([]byte, error) {
+	var vals map[string]any
+	if err := kyaml.Unmarshal([]byte(o.String()), &vals); err != nil {
+		return nil, err
+	}
+	stripOrchestratorAnnotations(vals)
+	if md, ok := vals["metadata"].(map[string]any); ok && len(md) == 0 {
+		delete(vals, "metadata")
+	}
+	return kyaml.Marshal(vals)
+}
+
+// stripOrchestratorAnnotations removes the annotations added by the orchestrator from an object
+func stripOrchestratorAnnotations(obj map[string]any) {
+	md, ok := obj["metadata"].(map[string]any)
+	if !ok {
+		return
+	}
+	if annos, ok := md["annotations"].(map[string]any); ok {
+		for k := range annos {
+			if strings.HasPrefix(k, "internal.config.kubernetes.io/") ||
+				k == kioutil.LegacyPathAnnotation || k == kioutil.LegacyIndexAnnotation || k == kioutil.LegacyIdAnnotation {
+				delete(annos, k)
+			}
+		}
+		if len(annos) == 0 {
+			delete(md, "annotations")
+		}
+	}
+}
+
+// chartValues returns the user-supplied values for a chart, i.e. the
+// values from `valuesFiles` combined with `valuesInline` according to
+// `valuesMerge`. Values files are looked up in `pkgValuesFiles` and,
+// if not found there, in the chart. Values are round-tripped through
+// YAML such that they are typed as if read from a values file by Helm
+func chartValues(chart *t.HelmChart, chrt *helmchart.Chart, pkgValuesFiles map[string][]byte) (map[string]any, error) {
+	values := &chart.Options.Values
+	b, err := kyaml.Marshal(values.ValuesInline)
+	if err != nil {
+		return nil, err
+	}
+	inline, err := chartutil.ReadValues(b)
+	if err != nil {
+		return nil, err
+	}
+	if values.ValuesMerge == t.ValuesMergeReplace {
+		return inline, nil
+	}
+
+	fromFiles := map[string]any{}
+	for _, name := range values.ValuesFiles {
+		b, found := pkgValuesFiles[name]
+		if !found {
+			b, found = chartFile(chrt, name)
+		}
+		if !found {
+			return nil, fmt.Errorf("values file %q not found in package or chart", name)
+		}
+		vals, err := chartutil.ReadValues(b)
+		if err != nil {
+			return nil, fmt.Errorf("parsing values file %q: %w", name, err)
+		}
+		fromFiles = mergeValues(fromFiles, vals)
+	}
+
+	if values.ValuesMerge == t.ValuesMergeMerge {
+		return mergeValues(inline, fromFiles), nil
+	}
+	return mergeValues(fromFiles, inline), nil
+}
+
+// chartFile returns the content of a file in the chart, with name relative to the chart root
+func chartFile(chrt *helmchart.Chart, name string) ([]byte, bool) {
+	name = path.Clean(name)
+	for _, f := range chrt.Raw {
+		if f.Name == name {
+			return f.Data, true
+		}
+	}
+	return nil, false
+}
+
+// mergeValues deep-merges src into dst with precedence to src. Maps
+// are merged recursively, all other values from src replace those
+// in dst
+func mergeValues(dst, src map[string]any) map[string]any {
+	merged := make(map[string]any, len(dst))
+	maps.Copy(merged, dst)
+	for k, v := range src {
+		if srcMap, ok := v.(map[string]any); ok {
+			if dstMap, ok := merged[k].(map[string]any); ok {
+				merged[k] = mergeValues(dstMap, srcMap)
+				continue
+			}
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// DefaultValuesDiff returns a unified diff of the default values of two
+// chart tarballs, i.e. their 'values.yaml'. The charts are named by
+// fromName and toName in the diff. Returns an empty diff if the default
+// values are equal
+func DefaultValuesDiff(fromChart, toChart []byte, fromName, toName string) (string, error) {
+	from, err := loader.LoadArchive(bytes.NewReader(fromChart))
+	if err != nil {
+		return "", fmt.Errorf("loading chart %v: %w", fromName, err)
+	}
+	to, err := loader.LoadArchive(bytes.NewReader(toChart))
+	if err != nil {
+		return "", fmt.Errorf("loading chart %v: %w", toName, err)
+	}
+	fromValues, _ := chartFile(from, chartutil.ValuesfileName)
+	toValues, _ := chartFile(to, chartutil.ValuesfileName)
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        valuesLines(fromValues),
+		B:        valuesLines(toValues),
+		FromFile: fromName + "/" + chartutil.ValuesfileName,
+		ToFile:   toName + "/" + chartutil.ValuesfileName,
+		Context:  3,
+	})
+}
+
+// valuesLines splits values into lines terminated by newlines
+func valuesLines(values []byte) []string {
+	lines := strings.SplitAfter(string(values), "\n")
+	if last := lines[len(lines)-1]; last == "" {
+		lines = lines[:len(lines)-1]
+	} else {
+		lines[len(lines)-1] = last + "\n"
+	}
+	return lines
+}
@@ -0,0 +1,113 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"os"
+	"testing"
+
+	"github.com/krm-functions/catalog/pkg/helmspecs"
+	"github.com/stretchr/testify/assert"
+	helmchart "helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+func TestChartValues(t *testing.T) {
+	chrt := &helmchart.Chart{
+		Raw: []*helmchart.File{
+			{Name: "values-prod.yaml", Data: []byte("a: chart\nb:\n  c: chart\n  d: chart\n")},
+		},
+	}
+	pkgFiles := map[string][]byte{
+		"values-pkg.yaml": []byte("b:\n  d: pkg\ne: pkg\n"),
+	}
+	inline := map[string]any{"a": "inline", "b": map[string]any{"c": "inline"}}
+
+	combs := []struct {
+		merge  string
+		expect map[string]any
+	}{
+		{"", map[string]any{"a": "inline", "b": map[string]any{"c": "inline", "d": "pkg"}, "e": "pkg"}},
+		{helmspecs.ValuesMergeOverride, map[string]any{"a": "inline", "b": map[string]any{"c": "inline", "d": "pkg"}, "e": "pkg"}},
+		{helmspecs.ValuesMergeMerge, map[string]any{"a": "chart", "b": map[string]any{"c": "chart", "d": "pkg"}, "e": "pkg"}},
+		{helmspecs.ValuesMergeReplace, map[string]any{"a": "inline", "b": map[string]any{"c": "inline"}}},
+	}
+	for _, test := range combs {
+		chart := &helmspecs.HelmChart{}
+		chart.Options.Values = helmspecs.HelmValues{
+			ValuesFiles:  []string{"values-prod.yaml", "values-pkg.yaml"},
+			ValuesInline: inline,
+			ValuesMerge:  test.merge,
+		}
+		vals, err := chartValues(chart, chrt, pkgFiles)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, test.expect, vals, "valuesMerge %q", test.merge)
+	}
+}
+
+func TestChartValuesMissingFile(t *testing.T) {
+	chart := &helmspecs.HelmChart{}
+	chart.Options.Values.ValuesFiles = []string{"missing.yaml"}
+	_, err := chartValues(chart, &helmchart.Chart{}, nil)
+	assert.Error(t, err)
+}
+
+// testValuesChart packages a chart with the given default values and
+// values schema, if not empty, and returns the tarball bytes
+func testValuesChart(t *testing.T, version, values, schema string) []byte {
+	t.Helper()
+	chrt := &helmchart.Chart{
+		Metadata: &helmchart.Metadata{APIVersion: helmchart.APIVersionV2, Name: "test-chart", Version: version},
+		Raw:      []*helmchart.File{{Name: chartutil.ValuesfileName, Data: []byte(values)}},
+	}
+	if schema != "" {
+		chrt.Schema = []byte(schema)
+	}
+	fname, err := chartutil.Save(chrt, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDefaultValuesDiff(t *testing.T) {
+	from := testValuesChart(t, "0.1.0", "replicas: 1\nimage:\n  tag: v1\n", "")
+	to := testValuesChart(t, "0.2.0", "replicas: 1\nimage:\n  tag: v2\n  pullPolicy: Always\n", "")
+
+	diff, err := DefaultValuesDiff(from, to, "test-chart-0.1.0", "test-chart-0.2.0")
+	assert.NoError(t, err)
+	assert.Equal(t, `--- test-chart-0.1.0/values.yaml
++++ test-chart-0.2.0/values.yaml
+@@ -1,3 +1,4 @@
+ replicas: 1
+ image:
+-  tag: v1
++  tag: v2
++  pullPolicy: Always
+`, diff)
+
+	diff, err = DefaultValuesDiff(from, from, "test-chart-0.1.0", "test-chart-0.1.0")
+	assert.NoError(t, err)
+	assert.Empty(t, diff)
+
+	_, err = DefaultValuesDiff(from, []byte("not a chart"), "test-chart-0.1.0", "broken")
+	assert.Error(t, err)
+}
@@ -16,34 +16,47 @@ package helmspecs
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 
+	"helm.sh/helm/v3/pkg/strvals"
+	ktypes "sigs.k8s.io/kustomize/api/types"
 	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
 // Kpt Helm related types
 type HelmChart struct {
-	Args    HelmChartArgs       `json:"chartArgs,omitempty" yaml:"chartArgs,omitempty"`
-	Options HelmTemplateOptions `json:"templateOptions,omitempty" yaml:"templateOptions,omitempty"`
+	Args       HelmChartArgs       `json:"chartArgs,omitempty" yaml:"chartArgs,omitempty"`
+	Options    HelmTemplateOptions `json:"templateOptions,omitempty" yaml:"templateOptions,omitempty"`
+	PostRender *HelmPostRender     `json:"postRender,omitempty" yaml:"postRender,omitempty"`
 	// This is an extension field from api version 'experimental.helm.sh/v1alpha1'
 	Chart string `json:"chart,omitempty" yaml:"chart,omitempty"`
 }
 type HelmChartArgs struct {
-	Name     string                    `json:"name,omitempty" yaml:"name,omitempty"`
-	Version  string                    `json:"version,omitempty" yaml:"version,omitempty"`
-	Repo     string                    `json:"repo,omitempty" yaml:"repo,omitempty"`
-	Registry string                    `json:"registry,omitempty" yaml:"registry,omitempty"`
-	Auth     *kyaml.ResourceIdentifier `json:"auth,omitempty" yaml:"auth,omitempty"`
+	Name      string                    `json:"name,omitempty" yaml:"name,omitempty"`
+	Version   string                    `json:"version,omitempty" yaml:"version,omitempty"`
+	Repo      string                    `json:"repo,omitempty" yaml:"repo,omitempty"`
+	Registry  string                    `json:"registry,omitempty" yaml:"registry,omitempty"`
+	Auth      *kyaml.ResourceIdentifier `json:"auth,omitempty" yaml:"auth,omitempty"`
+	Keyring   *kyaml.ResourceIdentifier `json:"keyring,omitempty" yaml:"keyring,omitempty"`
+	CosignKey *kyaml.ResourceIdentifier `json:"cosignKey,omitempty" yaml:"cosignKey,omitempty"`
+}
+type HelmPostRender struct {
+	Patches []ktypes.Patch `json:"patches,omitempty" yaml:"patches,omitempty"`
 }
 type HelmTemplateOptions struct {
-	APIVersions  []string   `json:"apiVersions,omitempty" yaml:"apiVersions,omitempty"`
-	KubeVersion  string     `json:"kubeVersion,omitempty" yaml:"kubeVersion,omitempty"`
-	ReleaseName  string     `json:"releaseName,omitempty" yaml:"releaseName,omitempty"`
-	Namespace    string     `json:"namespace,omitempty" yaml:"namespace,omitempty"`
-	Description  string     `json:"description,omitempty" yaml:"description,omitempty"`
-	NameTemplate string     `json:"nameTemplate,omitempty" yaml:"nameTemplate,omitempty"`
-	IncludeCRDs  bool       `json:"includeCRDs,omitempty" yaml:"includeCRDs,omitempty"`
-	SkipTests    bool       `json:"skipTests,omitempty" yaml:"skipTests,omitempty"`
-	Values       HelmValues `json:"values,omitempty" yaml:"values,omitempty"`
+	APIVersions    []string   `json:"apiVersions,omitempty" yaml:"apiVersions,omitempty"`
+	KubeVersion    string     `json:"kubeVersion,omitempty" yaml:"kubeVersion,omitempty"`
+	ReleaseName    string     `json:"releaseName,omitempty" yaml:"releaseName,omitempty"`
+	Namespace      string     `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Description    string     `json:"description,omitempty" yaml:"description,omitempty"`
+	NameTemplate   string     `json:"nameTemplate,omitempty" yaml:"nameTemplate,omitempty"`
+	IncludeCRDs    bool       `json:"includeCRDs,omitempty" yaml:"includeCRDs,omitempty"`
+	SkipTests      bool       `json:"skipTests,omitempty" yaml:"skipTests,omitempty"`
+	Hooks          string     `json:"hooks,omitempty" yaml:"hooks,omitempty"`
+	ClusterProfile string     `json:"clusterProfile,omitempty" yaml:"clusterProfile,omitempty"`
+	Lookup         bool       `json:"lookup,omitempty" yaml:"lookup,omitempty"`
+	Values         HelmValues `json:"values,omitempty" yaml:"values,omitempty"`
 }
 type HelmValues struct {
 	ValuesFiles  []string       `json:"valuesFiles,omitempty" yaml:"valuesFiles,omitempty"`
@@ -51,24 +64,141 @@ type HelmValues struct {
 	ValuesMerge  string         `json:"valuesMerge,omitempty" yaml:"valuesMerge,omitempty"`
 }
 
+// Legal values of `valuesMerge`, i.e. how `valuesInline` are combined with values from `valuesFiles`
+const (
+	ValuesMergeOverride = "override" // Inline values override values from files (default)
+	ValuesMergeMerge    = "merge"    // Values from files override inline values
+	ValuesMergeReplace  = "replace"  // Inline values replace values from files
+)
+
+// Legal values of `hooks`, i.e. how chart hooks are rendered
+const (
+	HooksKeep   = "keep"   // Hooks are rendered as ordinary objects (default)
+	HooksDrop   = "drop"   // Hooks are not rendered
+	HooksArgoCD = "argocd" // Hooks are translated to Argo CD hooks
+	HooksFlux   = "flux"   // Hooks are translated to objects re-created by Flux
+)
+
 // https://catalog.kpt.dev/render-helm-chart/v0.2/
 type RenderHelmChart struct {
 	Kind   string      `json:"kind,omitempty" yaml:"kind,omitempty"`
 	Charts []HelmChart `json:"helmCharts,omitempty" yaml:"helmCharts,omitempty"`
 }
 
+// ClusterProfile holds the Kubernetes version and API versions of a
+// target cluster, i.e. the capabilities charts are rendered with
+type ClusterProfile struct {
+	Kind        string   `json:"kind,omitempty" yaml:"kind,omitempty"`
+	KubeVersion string   `json:"kubeVersion,omitempty" yaml:"kubeVersion,omitempty"`
+	APIVersions []string `json:"apiVersions,omitempty" yaml:"apiVersions,omitempty"`
+}
+
 // ArgoCD Helm related types
 type ArgoCDHelmSource struct {
-	Name    string `json:"chart,omitempty" yaml:"chart,omitempty"`
-	Version string `json:"targetRevision,omitempty" yaml:"targetRevision,omitempty"`
-	Repo    string `json:"repoURL,omitempty" yaml:"repoURL,omitempty"`
+	Name    string             `json:"chart,omitempty" yaml:"chart,omitempty"`
+	Version string             `json:"targetRevision,omitempty" yaml:"targetRevision,omitempty"`
+	Repo    string             `json:"repoURL,omitempty" yaml:"repoURL,omitempty"`
+	Helm    *ArgoCDHelmOptions `json:"helm,omitempty" yaml:"helm,omitempty"`
+	// This is an extension field holding the chart embedded by source-helm-chart, similar to 'chart' of RenderHelmChart
+	Chart string `json:"embeddedChart,omitempty" yaml:"embeddedChart,omitempty"`
+}
+type ArgoCDHelmOptions struct {
+	ReleaseName  string                `json:"releaseName,omitempty" yaml:"releaseName,omitempty"`
+	Namespace    string                `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Values       string                `json:"values,omitempty" yaml:"values,omitempty"`
+	ValuesObject map[string]any        `json:"valuesObject,omitempty" yaml:"valuesObject,omitempty"`
+	ValueFiles   []string              `json:"valueFiles,omitempty" yaml:"valueFiles,omitempty"`
+	Parameters   []ArgoCDHelmParameter `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	SkipCrds     bool                  `json:"skipCrds,omitempty" yaml:"skipCrds,omitempty"`
+	SkipTests    bool                  `json:"skipTests,omitempty" yaml:"skipTests,omitempty"`
+	KubeVersion  string                `json:"kubeVersion,omitempty" yaml:"kubeVersion,omitempty"`
+	APIVersions  []string              `json:"apiVersions,omitempty" yaml:"apiVersions,omitempty"`
+}
+type ArgoCDHelmParameter struct {
+	Name        string `json:"name,omitempty" yaml:"name,omitempty"`
+	Value       string `json:"value,omitempty" yaml:"value,omitempty"`
+	ForceString bool   `json:"forceString,omitempty" yaml:"forceString,omitempty"`
+}
+type ArgoCDDestination struct {
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
 }
 type ArgoCDHelmSpec struct {
-	Source ArgoCDHelmSource `json:"source,omitempty" yaml:"source,omitempty"`
+	Source      ArgoCDHelmSource   `json:"source,omitempty" yaml:"source,omitempty"`
+	Sources     []ArgoCDHelmSource `json:"sources,omitempty" yaml:"sources,omitempty"`
+	Destination ArgoCDDestination  `json:"destination,omitempty" yaml:"destination,omitempty"`
+}
+type ArgoCDMetadata struct {
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
 }
 type ArgoCDHelmApp struct {
-	Kind string         `json:"kind,omitempty" yaml:"kind,omitempty"`
-	Spec ArgoCDHelmSpec `json:"spec,omitempty" yaml:"spec,omitempty"`
+	Kind     string         `json:"kind,omitempty" yaml:"kind,omitempty"`
+	Metadata ArgoCDMetadata `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	Spec     ArgoCDHelmSpec `json:"spec,omitempty" yaml:"spec,omitempty"`
+}
+
+// Flux Helm related types
+type FluxSourceReference struct {
+	Kind      string `json:"kind,omitempty" yaml:"kind,omitempty"`
+	Name      string `json:"name,omitempty" yaml:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+}
+type FluxHelmChartSpec struct {
+	Name        string              `json:"chart,omitempty" yaml:"chart,omitempty"`
+	Version     string              `json:"version,omitempty" yaml:"version,omitempty"`
+	SourceRef   FluxSourceReference `json:"sourceRef,omitempty" yaml:"sourceRef,omitempty"`
+	ValuesFiles []string            `json:"valuesFiles,omitempty" yaml:"valuesFiles,omitempty"`
+}
+type FluxHelmChartTemplate struct {
+	Spec FluxHelmChartSpec `json:"spec,omitempty" yaml:"spec,omitempty"`
+}
+type FluxValuesReference struct {
+	Kind       string `json:"kind,omitempty" yaml:"kind,omitempty"`
+	Name       string `json:"name,omitempty" yaml:"name,omitempty"`
+	ValuesKey  string `json:"valuesKey,omitempty" yaml:"valuesKey,omitempty"`
+	TargetPath string `json:"targetPath,omitempty" yaml:"targetPath,omitempty"`
+	Optional   bool   `json:"optional,omitempty" yaml:"optional,omitempty"`
+}
+type FluxInstall struct {
+	CRDs string `json:"crds,omitempty" yaml:"crds,omitempty"`
+}
+type FluxHelmReleaseSpec struct {
+	Chart           *FluxHelmChartTemplate `json:"chart,omitempty" yaml:"chart,omitempty"`
+	ChartRef        *FluxSourceReference   `json:"chartRef,omitempty" yaml:"chartRef,omitempty"`
+	ReleaseName     string                 `json:"releaseName,omitempty" yaml:"releaseName,omitempty"`
+	TargetNamespace string                 `json:"targetNamespace,omitempty" yaml:"targetNamespace,omitempty"`
+	Install         FluxInstall            `json:"install,omitempty" yaml:"install,omitempty"`
+	Values          map[string]any         `json:"values,omitempty" yaml:"values,omitempty"`
+	ValuesFrom      []FluxValuesReference  `json:"valuesFrom,omitempty" yaml:"valuesFrom,omitempty"`
+	// This is an extension field holding the chart embedded by source-helm-chart, similar to 'chart' of RenderHelmChart
+	EmbeddedChart string `json:"embeddedChart,omitempty" yaml:"embeddedChart,omitempty"`
+}
+type FluxMetadata struct {
+	Name      string `json:"name,omitempty" yaml:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+}
+type FluxHelmRelease struct {
+	Kind     string              `json:"kind,omitempty" yaml:"kind,omitempty"`
+	Metadata FluxMetadata        `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	Spec     FluxHelmReleaseSpec `json:"spec,omitempty" yaml:"spec,omitempty"`
+}
+type FluxSecretReference struct {
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+}
+type FluxOCIRepositoryRef struct {
+	Tag string `json:"tag,omitempty" yaml:"tag,omitempty"`
+}
+type FluxRepositorySpec struct {
+	URL       string               `json:"url,omitempty" yaml:"url,omitempty"`
+	SecretRef *FluxSecretReference `json:"secretRef,omitempty" yaml:"secretRef,omitempty"`
+	// Only for OCIRepository
+	Ref FluxOCIRepositoryRef `json:"ref,omitempty" yaml:"ref,omitempty"`
+}
+
+// FluxRepository is a HelmRepository or OCIRepository
+type FluxRepository struct {
+	Kind     string             `json:"kind,omitempty" yaml:"kind,omitempty"`
+	Metadata FluxMetadata       `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	Spec     FluxRepositorySpec `json:"spec,omitempty" yaml:"spec,omitempty"`
 }
 
 func ParseKptSpec(b []byte) (*RenderHelmChart, error) {
@@ -92,6 +222,16 @@ func (spec *RenderHelmChart) IsValidSpec() error {
 			return fmt.Errorf("chart name, version or repo cannot be empty (%s,%s,%s)",
 				chart.Args.Name, chart.Args.Version, chart.Args.Repo)
 		}
+		switch chart.Options.Values.ValuesMerge {
+		case "", ValuesMergeOverride, ValuesMergeMerge, ValuesMergeReplace:
+		default:
+			return fmt.Errorf("unsupported valuesMerge: %s", chart.Options.Values.ValuesMerge)
+		}
+		switch chart.Options.Hooks {
+		case "", HooksKeep, HooksDrop, HooksArgoCD, HooksFlux:
+		default:
+			return fmt.Errorf("unsupported hooks: %s", chart.Options.Hooks)
+		}
 		if chart.Args.Auth != nil {
 			if chart.Args.Auth.Kind != "Secret" {
 				return fmt.Errorf("chart auth kind must be 'Secret'")
@@ -100,6 +240,25 @@ func (spec *RenderHelmChart) IsValidSpec() error {
 				return fmt.Errorf("chart auth name must be defined")
 			}
 		}
+		if chart.Args.Keyring != nil {
+			if chart.Args.Keyring.Kind != "Secret" {
+				return fmt.Errorf("chart keyring kind must be 'Secret'")
+			}
+			if chart.Args.Keyring.Name == "" {
+				return fmt.Errorf("chart keyring name must be defined")
+			}
+		}
+		if chart.Args.CosignKey != nil {
+			if chart.Args.CosignKey.Kind != "Secret" && chart.Args.CosignKey.Kind != "ConfigMap" {
+				return fmt.Errorf("chart cosignKey kind must be 'Secret' or 'ConfigMap'")
+			}
+			if chart.Args.CosignKey.Name == "" {
+				return fmt.Errorf("chart cosignKey name must be defined")
+			}
+			if !strings.HasPrefix(chart.Args.Repo, "oci://") {
+				return fmt.Errorf("chart cosignKey requires an OCI repo (%s)", chart.Args.Repo)
+			}
+		}
 	}
 	return nil
 }
@@ -120,10 +279,103 @@ func (app *ArgoCDHelmApp) IsValidSpec() bool {
 }
 
 func (app *ArgoCDHelmApp) IsHelmSpec() bool {
-	if app.Spec.Source.Name == "" || app.Spec.Source.Version == "" || app.Spec.Source.Repo == "" {
-		return false
+	return app.Spec.Source.IsHelmSource()
+}
+
+// AllSources returns the sources of an application, i.e. 'sources'
+// for multi-source applications, or otherwise 'source'
+func (app *ArgoCDHelmApp) AllSources() []ArgoCDHelmSource {
+	if len(app.Spec.Sources) > 0 {
+		return app.Spec.Sources
+	}
+	return []ArgoCDHelmSource{app.Spec.Source}
+}
+
+// SourcePath returns the field path of a source returned by AllSources, e.g. 'spec.sources[1]'
+func (app *ArgoCDHelmApp) SourcePath(idx int) string {
+	if len(app.Spec.Sources) > 0 {
+		return fmt.Sprintf("spec.sources[%d]", idx)
+	}
+	return "spec.source"
+}
+
+// IsHelmSource returns true for sources of Helm charts, i.e. not Git sources
+func (asrc *ArgoCDHelmSource) IsHelmSource() bool {
+	return asrc.Name != "" && asrc.Version != "" && asrc.Repo != ""
+}
+
+// RefValueFile returns the index of the first value file of a source
+// referencing another source, e.g. '$values/values.yaml', or -1 if none
+func (asrc *ArgoCDHelmSource) RefValueFile() int {
+	if asrc.Helm == nil {
+		return -1
+	}
+	return slices.IndexFunc(asrc.Helm.ValueFiles, func(f string) bool { return strings.HasPrefix(f, "$") })
+}
+
+// ToHelmChart returns the chart spec of a source of an application,
+// with values and options as Argo CD renders the chart. Inline
+// values are 'valuesObject' or, if not given, 'values', with
+// 'parameters' set on top
+func (app *ArgoCDHelmApp) ToHelmChart(asrc *ArgoCDHelmSource) (*HelmChart, error) {
+	chart := &HelmChart{Args: asrc.ToKptSpec(), Chart: asrc.Chart}
+	opts := &chart.Options
+	opts.ReleaseName = app.Metadata.Name
+	opts.Namespace = app.Spec.Destination.Namespace
+	opts.IncludeCRDs = true
+	helm := asrc.Helm
+	if helm == nil {
+		return chart, nil
+	}
+	if helm.ReleaseName != "" {
+		opts.ReleaseName = helm.ReleaseName
+	}
+	if helm.Namespace != "" {
+		opts.Namespace = helm.Namespace
+	}
+	opts.IncludeCRDs = !helm.SkipCrds
+	opts.SkipTests = helm.SkipTests
+	opts.KubeVersion = helm.KubeVersion
+	opts.APIVersions = helm.APIVersions
+	if idx := asrc.RefValueFile(); idx >= 0 {
+		return nil, fmt.Errorf("value files from other sources are not supported (%s)", helm.ValueFiles[idx])
+	}
+	opts.Values.ValuesFiles = helm.ValueFiles
+
+	valuesYAML := []byte(helm.Values)
+	if len(helm.ValuesObject) > 0 {
+		var err error
+		if valuesYAML, err = kyaml.Marshal(helm.ValuesObject); err != nil {
+			return nil, err
+		}
+	}
+	values := map[string]any{}
+	if err := kyaml.Unmarshal(valuesYAML, &values); err != nil {
+		return nil, fmt.Errorf("parsing values: %w", err)
+	}
+	for _, p := range helm.Parameters {
+		param := p.Name + "=" + p.Value
+		var err error
+		if p.ForceString {
+			err = strvals.ParseIntoString(param, values)
+		} else {
+			err = strvals.ParseInto(param, values)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing parameter %v: %w", p.Name, err)
+		}
 	}
-	return true
+	opts.Values.ValuesInline = values
+	return chart, nil
+}
+
+// Values returns the values set by a parameter, e.g. 'image: {tag: v2}' for 'image.tag=v2'
+func (p *ArgoCDHelmParameter) Values() (map[string]any, error) {
+	param := p.Name + "=" + p.Value
+	if p.ForceString {
+		return strvals.ParseString(param)
+	}
+	return strvals.Parse(param)
 }
 
 func (asrc *ArgoCDHelmSource) ToKptSpec() HelmChartArgs {
@@ -133,3 +385,114 @@ func (asrc *ArgoCDHelmSource) ToKptSpec() HelmChartArgs {
 	ksrc.Repo = asrc.Repo
 	return ksrc
 }
+
+func ParseFluxHelmRelease(b []byte) (*FluxHelmRelease, error) {
+	release := &FluxHelmRelease{}
+	if err := kyaml.Unmarshal(b, release); err != nil {
+		return nil, err
+	}
+	if release.Kind != "HelmRelease" {
+		return release, fmt.Errorf("unsupported kind: %s", release.Kind)
+	}
+	if (release.Spec.Chart == nil) == (release.Spec.ChartRef == nil) {
+		return release, fmt.Errorf("HelmRelease %s: exactly one of chart and chartRef must be given", release.Metadata.Name)
+	}
+	return release, nil
+}
+
+func ParseFluxRepository(b []byte) (*FluxRepository, error) {
+	repo := &FluxRepository{}
+	if err := kyaml.Unmarshal(b, repo); err != nil {
+		return nil, err
+	}
+	if repo.Kind != "HelmRepository" && repo.Kind != "OCIRepository" {
+		return repo, fmt.Errorf("unsupported kind: %s", repo.Kind)
+	}
+	return repo, nil
+}
+
+// SourceRef returns the reference to the repository holding the chart
+// of a release, i.e. a HelmRepository or OCIRepository, with the
+// namespace defaulting to the namespace of the release
+func (release *FluxHelmRelease) SourceRef() FluxSourceReference {
+	var ref FluxSourceReference
+	if release.Spec.ChartRef != nil {
+		ref = *release.Spec.ChartRef
+	} else {
+		ref = release.Spec.Chart.Spec.SourceRef
+	}
+	if ref.Namespace == "" {
+		ref.Namespace = release.Metadata.Namespace
+	}
+	return ref
+}
+
+// IsHelmSource returns true for releases with charts from Helm or OCI
+// repositories, i.e. not from Git or buckets. Only an OCIRepository is
+// supported as chartRef, i.e. not a HelmChart
+func (release *FluxHelmRelease) IsHelmSource() bool {
+	kind := release.SourceRef().Kind
+	if release.Spec.ChartRef != nil {
+		return kind == "OCIRepository"
+	}
+	return kind == "HelmRepository" || kind == "OCIRepository"
+}
+
+// ToKptSpec returns the chart of a release from its repository. Charts
+// of an OCIRepository are given by the repository URL and tag, while
+// charts of a HelmRepository are given by the release
+func (release *FluxHelmRelease) ToKptSpec(repo *FluxRepository) (HelmChartArgs, error) {
+	args := HelmChartArgs{}
+	if repo.Spec.SecretRef != nil {
+		args.Auth = &kyaml.ResourceIdentifier{
+			TypeMeta: kyaml.TypeMeta{Kind: "Secret"},
+			NameMeta: kyaml.NameMeta{Name: repo.Spec.SecretRef.Name, Namespace: repo.Metadata.Namespace},
+		}
+	}
+	if repo.Kind == "OCIRepository" {
+		if repo.Spec.Ref.Tag == "" {
+			return args, fmt.Errorf("OCIRepository %s: ref.tag required", repo.Metadata.Name)
+		}
+		// The URL is the repository and chart name, e.g. 'oci://ghcr.io/org/chart'
+		url := repo.Spec.URL
+		idx := strings.LastIndex(url, "/")
+		if idx <= 0 || idx == len(url)-1 || strings.HasSuffix(url[:idx], "/") {
+			return args, fmt.Errorf("OCIRepository %s: invalid url %q, expected oci://<registry>/<chart>", repo.Metadata.Name, url)
+		}
+		args.Repo, args.Name, args.Version = url[:idx], url[idx+1:], repo.Spec.Ref.Tag
+		return args, nil
+	}
+	if release.Spec.Chart == nil {
+		return args, fmt.Errorf("HelmRelease %s: chart required with %s %s", release.Metadata.Name, repo.Kind, repo.Metadata.Name)
+	}
+	if repo.Spec.URL == "" {
+		return args, fmt.Errorf("%s %s: url required", repo.Kind, repo.Metadata.Name)
+	}
+	args.Repo = repo.Spec.URL
+	args.Name = release.Spec.Chart.Spec.Name
+	args.Version = release.Spec.Chart.Spec.Version
+	return args, nil
+}
+
+// ToHelmChart returns the chart spec of a release, with options as
+// Flux renders the chart. Values are the values of the release
+// combined with those of 'valuesFrom', see helm.FluxValues
+func (release *FluxHelmRelease) ToHelmChart(args HelmChartArgs, values map[string]any) *HelmChart {
+	chart := &HelmChart{Args: args, Chart: release.Spec.EmbeddedChart}
+	opts := &chart.Options
+	opts.ReleaseName = release.Metadata.Name
+	opts.Namespace = release.Metadata.Namespace
+	if release.Spec.TargetNamespace != "" {
+		opts.ReleaseName = release.Spec.TargetNamespace + "-" + release.Metadata.Name
+		opts.Namespace = release.Spec.TargetNamespace
+	}
+	if release.Spec.ReleaseName != "" {
+		opts.ReleaseName = release.Spec.ReleaseName
+	}
+	opts.IncludeCRDs = release.Spec.Install.CRDs != "Skip"
+	if release.Spec.Chart != nil {
+		opts.Values.ValuesFiles = release.Spec.Chart.Spec.ValuesFiles
+	}
+	opts.Values.ValuesInline = values
+	return chart
+}
@@ -0,0 +1,194 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmspecs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testArgoCDApp = `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: my-app
+spec:
+  destination:
+    namespace: apps
+  sources:
+  - repoURL: https://github.com/example/values.git
+    targetRevision: main
+    ref: values
+  - repoURL: https://charts.example.com
+    chart: my-chart
+    targetRevision: 1.2.3
+    embeddedChart: Y2hhcnQ=
+    helm:
+      releaseName: my-release
+      skipCrds: true
+      valuesObject:
+        image:
+          tag: v1
+        replicas: 2
+      values: |
+        ignored: true
+      parameters:
+      - name: image.tag
+        value: v2
+      - name: port
+        value: "8080"
+        forceString: true
+`
+
+func TestArgoCDToHelmChart(t *testing.T) {
+	app, err := ParseArgoCDSpec([]byte(testArgoCDApp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, app.IsHelmSpec())
+	srcs := app.AllSources()
+	assert.Len(t, srcs, 2)
+	assert.False(t, srcs[0].IsHelmSource())
+	assert.True(t, srcs[1].IsHelmSource())
+	assert.Equal(t, "spec.sources[1]", app.SourcePath(1))
+
+	chart, err := app.ToHelmChart(&srcs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, HelmChartArgs{Name: "my-chart", Version: "1.2.3", Repo: "https://charts.example.com"}, chart.Args)
+	assert.Equal(t, "Y2hhcnQ=", chart.Chart)
+	assert.Equal(t, "my-release", chart.Options.ReleaseName)
+	assert.Equal(t, "apps", chart.Options.Namespace)
+	assert.False(t, chart.Options.IncludeCRDs)
+	assert.Equal(t, map[string]any{
+		"image":    map[string]any{"tag": "v2"},
+		"replicas": 2,
+		"port":     "8080",
+	}, chart.Options.Values.ValuesInline)
+	params, err := srcs[1].Helm.Parameters[1].Values()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]any{"port": "8080"}, params)
+	// Parameters do not modify the application values
+	assert.Equal(t, "v1", srcs[1].Helm.ValuesObject["image"].(map[string]any)["tag"])
+
+	// Value files from other sources are not supported
+	srcs[1].Helm = &ArgoCDHelmOptions{Values: "replicas: 3\n", ValueFiles: []string{"$values/values.yaml"}}
+	_, err = app.ToHelmChart(&srcs[1])
+	assert.Error(t, err)
+	assert.Equal(t, 0, srcs[1].RefValueFile())
+
+	// Release name defaults to the application name
+	srcs[1].Helm.ValueFiles = nil
+	assert.Equal(t, -1, srcs[1].RefValueFile())
+	chart, err = app.ToHelmChart(&srcs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "my-app", chart.Options.ReleaseName)
+	assert.True(t, chart.Options.IncludeCRDs)
+	assert.Equal(t, map[string]any{"replicas": 3}, chart.Options.Values.ValuesInline)
+}
+
+const testFluxRelease = `apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: my-release
+  namespace: apps
+spec:
+  chartRef:
+    kind: OCIRepository
+    name: my-chart
+`
+
+func TestFluxChartRef(t *testing.T) {
+	release, err := ParseFluxHelmRelease([]byte(testFluxRelease))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, release.IsHelmSource())
+	assert.Equal(t, FluxSourceReference{Kind: "OCIRepository", Name: "my-chart", Namespace: "apps"}, release.SourceRef())
+
+	// Charts of a HelmRepository are given by 'chart', not by 'chartRef'
+	repo := &FluxRepository{Kind: "HelmRepository"}
+	repo.Spec.URL = "https://charts.example.com"
+	_, err = release.ToKptSpec(repo)
+	assert.Error(t, err)
+
+	// Only OCIRepository is supported for 'chartRef'
+	release, err = ParseFluxHelmRelease([]byte(strings.Replace(testFluxRelease, "OCIRepository", "HelmChart", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, release.IsHelmSource())
+}
+
+func TestFluxToKptSpec(t *testing.T) {
+	chartRelease, err := ParseFluxHelmRelease([]byte(`apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: my-release
+spec:
+  chart:
+    spec:
+      chart: my-chart
+      version: 1.2.3
+      sourceRef:
+        kind: HelmRepository
+        name: charts
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	refRelease, err := ParseFluxHelmRelease([]byte(testFluxRelease))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		release  *FluxHelmRelease
+		kind     string
+		url      string
+		expected HelmChartArgs
+		invalid  bool
+	}{
+		{"helm repository", chartRelease, "HelmRepository", "https://charts.example.com",
+			HelmChartArgs{Name: "my-chart", Version: "1.2.3", Repo: "https://charts.example.com"}, false},
+		{"helm repository without url", chartRelease, "HelmRepository", "", HelmChartArgs{}, true},
+		{"oci repository", refRelease, "OCIRepository", "oci://ghcr.io/org/my-chart",
+			HelmChartArgs{Name: "my-chart", Version: "1.0.0", Repo: "oci://ghcr.io/org"}, false},
+		{"oci repository without url", refRelease, "OCIRepository", "", HelmChartArgs{}, true},
+		{"oci repository url without slash", refRelease, "OCIRepository", "my-chart", HelmChartArgs{}, true},
+		{"oci repository url without registry", refRelease, "OCIRepository", "oci://my-chart", HelmChartArgs{}, true},
+		{"oci repository url without chart", refRelease, "OCIRepository", "oci://ghcr.io/", HelmChartArgs{}, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &FluxRepository{Kind: tc.kind}
+			repo.Spec.URL = tc.url
+			repo.Spec.Ref.Tag = "1.0.0"
+			args, err := tc.release.ToKptSpec(repo)
+			if tc.invalid {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, args)
+		})
+	}
+}
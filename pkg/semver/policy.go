@@ -0,0 +1,185 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Legal values of the maximum upgrade distance
+const (
+	DistanceMajor = "major"
+	DistanceMinor = "minor"
+	DistancePatch = "patch"
+)
+
+var distanceRanks = map[string]int{DistancePatch: 0, DistanceMinor: 1, DistanceMajor: 2}
+
+// Policy restricts the versions a chart may be upgraded to
+type Policy struct {
+	// Constraint is a version constraint, e.g. '1.8.*'. Defaults to any version
+	Constraint string
+	// MaxDistance is the largest distance of an upgrade, i.e. 'major', 'minor' or 'patch', measured on the first,
+	// second and remaining segments of versions. Defaults to no limit
+	MaxDistance string
+	// MinReleaseAge is the minimum age of a version. Versions without a release time are not restricted
+	MinReleaseAge time.Duration
+	// Deny are versions or constraints, e.g. '1.2.3' or '>=2.0.0 <2.0.4', of versions never upgraded to
+	Deny []string
+	// Prerelease allows upgrades to prerelease versions satisfying the constraint
+	Prerelease bool
+	// Scheme is the version scheme. Defaults to semver
+	Scheme Scheme
+}
+
+// Release is a version of a chart
+type Release struct {
+	Version string
+	Created time.Time
+}
+
+// Validate checks the syntax of a policy
+func (p *Policy) Validate() error {
+	if p.MaxDistance != "" {
+		if _, found := distanceRanks[p.MaxDistance]; !found {
+			return fmt.Errorf("unsupported max distance: %s", p.MaxDistance)
+		}
+	}
+	for _, d := range p.Deny {
+		if _, err := p.VersionScheme().ParseConstraint(d); err != nil {
+			return fmt.Errorf("error parsing denied version %q: %w", d, err)
+		}
+	}
+	return nil
+}
+
+// VersionScheme returns the version scheme of the policy
+func (p *Policy) VersionScheme() Scheme {
+	if p.Scheme == nil {
+		return SemVer
+	}
+	return p.Scheme
+}
+
+// Upgrade returns the highest version of releases allowed by the policy.
+// Versions beyond the maximum distance are measured from the current version.
+// Returns the current version if no higher version is allowed
+func (p *Policy) Upgrade(current string, releases []Release, now time.Time) (string, error) {
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+	scheme := p.VersionScheme()
+	constraint := p.Constraint
+	if constraint == "" {
+		constraint = "*"
+	}
+	constraints, err := scheme.ParseConstraint(constraint)
+	if err != nil {
+		return "", fmt.Errorf("error parsing constraint %q: %q", constraint, err.Error())
+	}
+	created := make(map[string]time.Time, len(releases))
+	versions := make([]string, 0, len(releases))
+	for _, r := range releases {
+		created[r.Version] = r.Created
+		versions = append(versions, r.Version)
+	}
+	cur, _ := parseDistance(scheme, current)
+
+	satisfied := false
+	for _, v := range SortScheme(scheme, versions) {
+		if !p.checkConstraint(constraints, v) {
+			continue
+		}
+		satisfied = true
+		// Versions are sorted, i.e. no remaining version is an upgrade
+		if v.Original() == current || (cur != nil && v.Compare(cur) <= 0) {
+			return current, nil
+		}
+		if p.denied(v) {
+			continue
+		}
+		if t := created[v.Original()]; p.MinReleaseAge > 0 && !t.IsZero() && now.Sub(t) < p.MinReleaseAge {
+			continue
+		}
+		if cur != nil {
+			within, dErr := p.withinDistance(cur, v)
+			if dErr != nil {
+				return "", dErr
+			}
+			if !within {
+				continue
+			}
+		}
+		return v.Original(), nil
+	}
+	if !satisfied {
+		return "", fmt.Errorf("no version found that satisfies constraint: %q", constraint)
+	}
+	return current, nil
+}
+
+// checkConstraint checks a version against the constraint. With
+// prereleases allowed, prerelease versions are checked by their release version
+func (p *Policy) checkConstraint(constraints Constraint, v Version) bool {
+	if p.Prerelease && v.Prerelease() {
+		return constraints.Check(v.Release())
+	}
+	return constraints.Check(v)
+}
+
+func (p *Policy) denied(v Version) bool {
+	for _, d := range p.Deny {
+		if c, err := p.VersionScheme().ParseConstraint(d); err == nil && c.Check(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// withinDistance checks the distance between versions. Returns an
+// error for versions without numeric segments, e.g. lexical versions
+func (p *Policy) withinDistance(from, to Version) (bool, error) {
+	if p.MaxDistance == "" {
+		return true, nil
+	}
+	fromSegs, toSegs := from.Segments(), to.Segments()
+	if fromSegs == nil || toSegs == nil {
+		return false, fmt.Errorf("max distance not supported for versions %q and %q", from.Original(), to.Original())
+	}
+	rank := distanceRanks[DistancePatch]
+	switch {
+	case segment(toSegs, 0) != segment(fromSegs, 0):
+		rank = distanceRanks[DistanceMajor]
+	case segment(toSegs, 1) != segment(fromSegs, 1):
+		rank = distanceRanks[DistanceMinor]
+	}
+	return rank <= distanceRanks[p.MaxDistance], nil
+}
+
+// ParseAge parses a minimum release age as a number of days, e.g. '7' or '7d', or as a duration, e.g. '36h'
+func ParseAge(age string) (time.Duration, error) {
+	days, err := strconv.Atoi(strings.TrimSuffix(age, "d"))
+	if err == nil {
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(age)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing release age %q: expected days or duration", age)
+	}
+	return d, nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyUpgrade(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	days := func(n int) time.Time { return now.Add(-time.Duration(n) * 24 * time.Hour) }
+	releases := []Release{
+		{"1.2.0", days(100)},
+		{"1.2.1", days(50)},
+		{"1.2.2", days(40)},
+		{"1.2.3", days(2)},
+		{"1.3.0", days(30)},
+		{"1.4.0-rc.1", days(20)},
+		{"2.0.0", days(10)},
+	}
+
+	tests := []struct {
+		name     string
+		policy   Policy
+		expected string
+	}{
+		{"any", Policy{}, "2.0.0"},
+		{"constraint", Policy{Constraint: "1.2.*"}, "1.2.3"},
+		{"patch", Policy{MaxDistance: DistancePatch}, "1.2.3"},
+		{"minor", Policy{MaxDistance: DistanceMinor}, "1.3.0"},
+		{"major", Policy{MaxDistance: DistanceMajor}, "2.0.0"},
+		{"release age", Policy{MaxDistance: DistancePatch, MinReleaseAge: 7 * 24 * time.Hour}, "1.2.2"},
+		{"release age all too recent", Policy{Constraint: "1.2.3", MinReleaseAge: 7 * 24 * time.Hour}, "1.2.0"},
+		{"deny", Policy{Deny: []string{"2.0.0", ">=1.3.0 <1.4.0"}}, "1.2.3"},
+		{"prerelease", Policy{Constraint: "<2.0.0", Prerelease: true}, "1.4.0-rc.1"},
+		{"no prerelease", Policy{Constraint: "<2.0.0"}, "1.3.0"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			upgraded, err := tc.policy.Upgrade("1.2.0", releases, now)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, upgraded)
+		})
+	}
+
+	// Never downgrade, e.g. when the current version is not released
+	upgraded, err := (&Policy{Deny: []string{"2.0.0"}}).Upgrade("1.9.0", []Release{{"2.0.0", now}, {"1.0.0", now}}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.9.0", upgraded)
+	upgraded, err = (&Policy{MaxDistance: DistanceMinor}).Upgrade("1.5.2", []Release{{"2.0.0", now}, {"1.4.0", now}}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.5.2", upgraded)
+
+	_, err = (&Policy{Constraint: "3.*"}).Upgrade("1.2.0", releases, now)
+	assert.Error(t, err)
+	_, err = (&Policy{MaxDistance: "huge"}).Upgrade("1.2.0", releases, now)
+	assert.Error(t, err)
+	_, err = (&Policy{Deny: []string{"not-a-version"}}).Upgrade("1.2.0", releases, now)
+	assert.Error(t, err)
+}
+
+func TestParseAge(t *testing.T) {
+	for age, expected := range map[string]time.Duration{
+		"7":   7 * 24 * time.Hour,
+		"7d":  7 * 24 * time.Hour,
+		"36h": 36 * time.Hour,
+	} {
+		d, err := ParseAge(age)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, d)
+	}
+	_, err := ParseAge("soon")
+	assert.Error(t, err)
+}
@@ -0,0 +1,447 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semver
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	version "github.com/Masterminds/semver/v3"
+)
+
+// Names of version schemes, see ParseScheme
+const (
+	SchemeSemVer  = "semver"
+	SchemeCalVer  = "calver"
+	SchemeLexical = "lexical"
+	SchemeRegex   = "regex"
+)
+
+// Scheme is a versioning scheme, i.e. how versions are parsed and ordered
+type Scheme interface {
+	// Parse parses a version. Returns an error for versions not valid in the scheme
+	Parse(raw string) (Version, error)
+	// ParseConstraint parses a constraint on versions of the scheme
+	ParseConstraint(constraint string) (Constraint, error)
+}
+
+// Version is a version parsed by a Scheme
+type Version interface {
+	Original() string
+	// Compare returns -1, 0 or 1 if the version is lower than, equal to or higher than another version of the same scheme
+	Compare(other Version) int
+	// Segments returns the numeric segments of the version, most significant first, or nil if the version has none
+	Segments() []uint64
+	// Prerelease returns true for prerelease versions
+	Prerelease() bool
+	// Release returns the version without prerelease
+	Release() Version
+}
+
+// Constraint restricts the versions of a scheme
+type Constraint interface {
+	Check(v Version) bool
+}
+
+// ParseScheme returns a version scheme by name:
+//
+//   - 'semver' (default): semver-2 versions with an optional leading 'v'.
+//   - 'calver': numeric segments separated by '.' or '-' with an optional leading 'v', e.g. '2024.05.01'.
+//   - 'lexical': any version, ordered lexically.
+//   - 'regex:<pattern>': versions matching the pattern, ordered by its capture groups. Groups are
+//     compared numerically if numeric and otherwise lexically, e.g. 'regex:^(\d+)\.(\d+)\.(\d+)-r(\d+)$'.
+func ParseScheme(name string) (Scheme, error) {
+	switch {
+	case name == "" || name == SchemeSemVer:
+		return SemVer, nil
+	case name == SchemeCalVer:
+		return CalVer, nil
+	case name == SchemeLexical:
+		return Lexical, nil
+	case strings.HasPrefix(name, SchemeRegex+":"):
+		return NewRegexScheme(strings.TrimPrefix(name, SchemeRegex+":"))
+	}
+	return nil, fmt.Errorf("unsupported version scheme: %s", name)
+}
+
+// SortScheme returns the versions valid in a scheme in descending order
+func SortScheme(scheme Scheme, versionsRaw []string) []Version {
+	versions := make([]Version, 0, len(versionsRaw))
+	for _, raw := range versionsRaw {
+		if v, err := scheme.Parse(raw); err == nil {
+			versions = append(versions, v)
+		}
+	}
+	slices.SortStableFunc(versions, func(a, b Version) int {
+		return b.Compare(a)
+	})
+	return versions
+}
+
+// UpgradeScheme returns the highest version of a scheme that fulfill constraint
+func UpgradeScheme(scheme Scheme, versions []string, constraint string) (string, error) {
+	c, err := scheme.ParseConstraint(constraint)
+	if err != nil {
+		return "", fmt.Errorf("error parsing constraint %q: %q", constraint, err.Error())
+	}
+	for _, v := range SortScheme(scheme, versions) {
+		if c.Check(v) {
+			return v.Original(), nil
+		}
+	}
+	return "", fmt.Errorf("no version found that satisfies constraint: %q", constraint)
+}
+
+// DiffScheme calculates the difference between two versions of a
+// scheme as the difference of the leftmost differing segment, see
+// Diff. Returns an empty difference for versions without segments
+func DiffScheme(scheme Scheme, fromVer, toVer string) (string, error) {
+	from, err := parseDistance(scheme, fromVer)
+	if err != nil {
+		return "", err
+	}
+	to, err := parseDistance(scheme, toVer)
+	if err != nil {
+		return "", err
+	}
+	fromSegs, toSegs := from.Segments(), to.Segments()
+	if fromSegs == nil || toSegs == nil {
+		return "", nil
+	}
+	diff := make([]string, max(len(fromSegs), len(toSegs)))
+	differs := false
+	for idx := range diff {
+		var d uint64
+		if !differs {
+			d = segment(toSegs, idx) - segment(fromSegs, idx)
+			differs = d != 0
+		}
+		diff[idx] = strconv.FormatUint(d, 10)
+	}
+	return strings.Join(diff, "."), nil
+}
+
+// parseDistance parses a version for measuring distances. Semver
+// versions are parsed leniently, e.g. '1.2' as '1.2.0', since the
+// versions measured need not be valid for upgrades
+func parseDistance(scheme Scheme, raw string) (Version, error) {
+	if _, ok := scheme.(semVerScheme); ok {
+		v, err := version.NewVersion(raw)
+		if err != nil {
+			return nil, err
+		}
+		return semVerVersion{v}, nil
+	}
+	return scheme.Parse(raw)
+}
+
+func segment(segs []uint64, idx int) uint64 {
+	if idx < len(segs) {
+		return segs[idx]
+	}
+	return 0
+}
+
+// SemVer is the semver-2 version scheme
+var SemVer Scheme = semVerScheme{}
+
+type semVerScheme struct{}
+
+type semVerVersion struct {
+	*version.Version
+}
+
+type semVerConstraint struct {
+	*version.Constraints
+}
+
+// parseSemVer parses a strict semver-2 version with an optional leading 'v'
+func parseSemVer(raw string) (*version.Version, error) {
+	if _, err := version.StrictNewVersion(strings.TrimPrefix(raw, "v")); err != nil { // Only accept semver-2
+		return nil, err
+	}
+	return version.NewVersion(raw) // Parse with optional leading 'v'
+}
+
+func (semVerScheme) Parse(raw string) (Version, error) {
+	v, err := parseSemVer(raw)
+	if err != nil {
+		return nil, err
+	}
+	return semVerVersion{v}, nil
+}
+
+func (semVerScheme) ParseConstraint(constraint string) (Constraint, error) {
+	c, err := version.NewConstraint(constraint)
+	if err != nil {
+		return nil, err
+	}
+	return semVerConstraint{c}, nil
+}
+
+func (v semVerVersion) Compare(other Version) int {
+	return v.Version.Compare(other.(semVerVersion).Version)
+}
+
+func (v semVerVersion) Segments() []uint64 {
+	return []uint64{v.Major(), v.Minor(), v.Patch()}
+}
+
+func (v semVerVersion) Prerelease() bool {
+	return v.Version.Prerelease() != ""
+}
+
+func (v semVerVersion) Release() Version {
+	release, err := v.SetPrerelease("")
+	if err != nil {
+		return v
+	}
+	return semVerVersion{&release}
+}
+
+func (c semVerConstraint) Check(v Version) bool {
+	sv, ok := v.(semVerVersion)
+	return ok && c.Constraints.Check(sv.Version)
+}
+
+// CalVer is the calendar version scheme, or generally versions of numeric segments
+var CalVer Scheme = calVerScheme{}
+
+type calVerScheme struct{}
+
+var calVerRegex = regexp.MustCompile(`^v?\d+([.-]\d+)*$`)
+
+func (calVerScheme) Parse(raw string) (Version, error) {
+	if !calVerRegex.MatchString(raw) {
+		return nil, fmt.Errorf("invalid calver version: %q", raw)
+	}
+	fields := strings.FieldsFunc(strings.TrimPrefix(raw, "v"), func(r rune) bool { return r == '.' || r == '-' })
+	segs := make([]uint64, len(fields))
+	for idx, f := range fields {
+		n, err := strconv.ParseUint(f, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid calver version: %q: %w", raw, err)
+		}
+		segs[idx] = n
+	}
+	return &segmentsVersion{original: raw, segments: segs}, nil
+}
+
+func (s calVerScheme) ParseConstraint(constraint string) (Constraint, error) {
+	return parseConstraint(s, constraint)
+}
+
+// segmentsVersion is a version ordered by its numeric segments, e.g. a calver version
+type segmentsVersion struct {
+	original string
+	segments []uint64
+}
+
+func (v *segmentsVersion) Original() string   { return v.original }
+func (v *segmentsVersion) Segments() []uint64 { return v.segments }
+func (v *segmentsVersion) Prerelease() bool   { return false }
+func (v *segmentsVersion) Release() Version   { return v }
+
+func (v *segmentsVersion) Compare(other Version) int {
+	return slices.Compare(v.segments, other.(*segmentsVersion).segments)
+}
+
+// Lexical is the version scheme of any versions ordered lexically
+var Lexical Scheme = lexicalScheme{}
+
+type lexicalScheme struct{}
+
+type lexicalVersion string
+
+func (lexicalScheme) Parse(raw string) (Version, error) {
+	if raw == "" {
+		return nil, errors.New("empty version")
+	}
+	return lexicalVersion(raw), nil
+}
+
+func (s lexicalScheme) ParseConstraint(constraint string) (Constraint, error) {
+	return parseConstraint(s, constraint)
+}
+
+func (v lexicalVersion) Original() string   { return string(v) }
+func (v lexicalVersion) Segments() []uint64 { return nil }
+func (v lexicalVersion) Prerelease() bool   { return false }
+func (v lexicalVersion) Release() Version   { return v }
+func (v lexicalVersion) Compare(other Version) int {
+	return strings.Compare(string(v), string(other.(lexicalVersion)))
+}
+
+type regexScheme struct {
+	re *regexp.Regexp
+}
+
+type regexVersion struct {
+	original string
+	groups   []string
+}
+
+// NewRegexScheme returns a version scheme of versions matching a
+// pattern with at least one capture group. Versions are ordered by the
+// capture groups, which are compared numerically if both are numeric
+func NewRegexScheme(pattern string) (Scheme, error) {
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid version pattern: %w", err)
+	}
+	if re.NumSubexp() == 0 {
+		return nil, fmt.Errorf("version pattern %q has no capture groups", pattern)
+	}
+	return regexScheme{re}, nil
+}
+
+func (s regexScheme) Parse(raw string) (Version, error) {
+	m := s.re.FindStringSubmatch(raw)
+	if m == nil {
+		return nil, fmt.Errorf("version %q does not match %q", raw, s.re)
+	}
+	return &regexVersion{original: raw, groups: m[1:]}, nil
+}
+
+func (s regexScheme) ParseConstraint(constraint string) (Constraint, error) {
+	return parseConstraint(s, constraint)
+}
+
+func (v *regexVersion) Original() string { return v.original }
+func (v *regexVersion) Prerelease() bool { return false }
+func (v *regexVersion) Release() Version { return v }
+
+// Segments returns the capture groups if all are numeric
+func (v *regexVersion) Segments() []uint64 {
+	segs := make([]uint64, len(v.groups))
+	for idx, g := range v.groups {
+		n, err := strconv.ParseUint(g, 10, 64)
+		if err != nil {
+			return nil
+		}
+		segs[idx] = n
+	}
+	return segs
+}
+
+func (v *regexVersion) Compare(other Version) int {
+	o := other.(*regexVersion)
+	for idx := range min(len(v.groups), len(o.groups)) {
+		a, aErr := strconv.ParseUint(v.groups[idx], 10, 64)
+		b, bErr := strconv.ParseUint(o.groups[idx], 10, 64)
+		var c int
+		if aErr == nil && bErr == nil {
+			c = cmp.Compare(a, b)
+		} else {
+			c = strings.Compare(v.groups[idx], o.groups[idx])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(v.groups), len(o.groups))
+}
+
+// schemeConstraint is a constraint of schemes other than semver. The
+// constraint is alternatives separated by '||' of terms separated by
+// spaces or commas. A term is a comparison, e.g. '>=2024.01', or a
+// prefix ending with '*', e.g. '2024.*'. An empty constraint or '*'
+// allows any version
+type schemeConstraint [][]constraintTerm
+
+type constraintTerm struct {
+	op     string
+	v      Version
+	prefix string
+}
+
+var constraintOps = []string{">=", "<=", "!=", ">", "<", "="}
+
+var constraintOpSpaceRegex = regexp.MustCompile(`(>=|<=|!=|>|<|=)\s+`)
+
+func parseConstraint(scheme Scheme, constraint string) (Constraint, error) {
+	var c schemeConstraint
+	// Operators may be followed by spaces, e.g. '>= 2024.1', as with semver constraints
+	constraint = constraintOpSpaceRegex.ReplaceAllString(constraint, "$1")
+	for _, alt := range strings.Split(constraint, "||") {
+		var terms []constraintTerm
+		for _, f := range strings.FieldsFunc(alt, func(r rune) bool { return r == ' ' || r == ',' }) {
+			if strings.HasSuffix(f, "*") {
+				terms = append(terms, constraintTerm{prefix: strings.TrimSuffix(f, "*")})
+				continue
+			}
+			term := constraintTerm{op: "="}
+			for _, op := range constraintOps {
+				if strings.HasPrefix(f, op) {
+					term.op, f = op, strings.TrimSpace(strings.TrimPrefix(f, op))
+					break
+				}
+			}
+			v, err := scheme.Parse(f)
+			if err != nil {
+				return nil, err
+			}
+			term.v = v
+			terms = append(terms, term)
+		}
+		c = append(c, terms)
+	}
+	return c, nil
+}
+
+func (c schemeConstraint) Check(v Version) bool {
+	for _, terms := range c {
+		if c.checkTerms(terms, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c schemeConstraint) checkTerms(terms []constraintTerm, v Version) bool {
+	for _, t := range terms {
+		if t.v == nil {
+			if !strings.HasPrefix(v.Original(), t.prefix) {
+				return false
+			}
+			continue
+		}
+		r := v.Compare(t.v)
+		ok := false
+		switch t.op {
+		case "=":
+			ok = r == 0
+		case "!=":
+			ok = r != 0
+		case ">":
+			ok = r > 0
+		case ">=":
+			ok = r >= 0
+		case "<":
+			ok = r < 0
+		case "<=":
+			ok = r <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
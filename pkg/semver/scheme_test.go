@@ -0,0 +1,110 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpgradeScheme(t *testing.T) {
+	tests := []struct {
+		scheme     string
+		versions   []string
+		constraint string
+		expected   string
+	}{
+		{"semver", []string{"1.2.0", "v1.10.0", "2024.05.01", "latest"}, "*", "v1.10.0"},
+		{"calver", []string{"2024.05.01", "2024.12.1", "2025.01.02", "1.2.3-rc1", "latest"}, "*", "2025.01.02"},
+		{"calver", []string{"2024.05.01", "2024.12.1", "2025.01.02"}, "2024.*", "2024.12.1"},
+		{"calver", []string{"2024-05-01", "2024-12-01", "2025-01-02"}, ">=2024-06 <2025", "2024-12-01"},
+		{"calver", []string{"2024-05-01", "2024-12-01", "2025-01-02"}, ">= 2024-06 < 2025", "2024-12-01"},
+		{"calver", []string{"2023.12", "2024.1", "2024.2"}, ">=2024.1 <2024.2", "2024.1"},
+		{"calver", []string{"2023.12", "2024.1", "2024.2"}, ">= 2024.1, < 2024.2", "2024.1"},
+		{"calver", []string{"2024.05.01", "2024.12.1", "2025.01.02"}, "2023.* || 2024.05.01", "2024.05.01"},
+		{"lexical", []string{"bookworm", "bullseye", "trixie"}, "", "trixie"},
+		{"lexical", []string{"bookworm", "bullseye", "trixie"}, "<trixie, !=bullseye", "bookworm"},
+		{"lexical", []string{"bookworm", "bullseye", "trixie"}, "< trixie, != bullseye", "bookworm"},
+		{`regex:(\d+)\.(\d+)\.(\d+)-r(\d+)`, []string{"1.2.3-r9", "1.2.3-r10", "1.2.4", "1.2.2-r11"}, "*", "1.2.3-r10"},
+		{`regex:(\d+)\.(\d+)-(alpine|debian)`, []string{"1.2-alpine", "1.2-debian", "1.10-alpine"}, "1.2*", "1.2-debian"},
+		{`regex:(\d+)\.(\d+)-(alpine|debian)`, []string{"1.2-alpine", "1.2-debian", "1.10-alpine"}, "<1.10-alpine", "1.2-debian"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.scheme+" "+tc.constraint, func(t *testing.T) {
+			scheme, err := ParseScheme(tc.scheme)
+			assert.NoError(t, err)
+			upgraded, err := UpgradeScheme(scheme, tc.versions, tc.constraint)
+			if tc.expected == "" {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, upgraded)
+		})
+	}
+
+	for _, name := range []string{"date", "regex:(", "regex:\\d+"} {
+		_, err := ParseScheme(name)
+		assert.Error(t, err, name)
+	}
+	_, err := UpgradeScheme(CalVer, []string{"2024.05.01"}, ">=not-a-version")
+	assert.Error(t, err)
+}
+
+func TestDiffScheme(t *testing.T) {
+	regex, err := NewRegexScheme(`(\d+)\.(\d+)\.(\d+)-r(\d+)`)
+	assert.NoError(t, err)
+	tests := []struct {
+		scheme   Scheme
+		from, to string
+		expected string
+	}{
+		{SemVer, "1.6.99", "2.0.0", "1.0.0"},
+		{SemVer, "v1.2.3", "v1.2.5", "0.0.2"},
+		{SemVer, "1.2", "1.2", "0.0.0"},
+		{SemVer, "1.2", "1.3.1", "0.1.0"},
+		{CalVer, "2024.05.01", "2024.06.01", "0.1.0"},
+		{CalVer, "2024.05", "2024.05.01", "0.0.1"},
+		{regex, "1.2.3-r9", "1.2.3-r10", "0.0.0.1"},
+		{Lexical, "bookworm", "trixie", ""},
+	}
+	for _, tc := range tests {
+		diff, dErr := DiffScheme(tc.scheme, tc.from, tc.to)
+		assert.NoError(t, dErr)
+		assert.Equal(t, tc.expected, diff, tc.from+" -> "+tc.to)
+	}
+	_, err = DiffScheme(CalVer, "2024.05.01", "latest")
+	assert.Error(t, err)
+}
+
+func TestPolicyUpgradeScheme(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	releases := []Release{
+		{"2024.05.01", now},
+		{"2024.11.02", now},
+		{"2025.02.01", now},
+	}
+	upgraded, err := (&Policy{Scheme: CalVer, MaxDistance: DistanceMinor}).Upgrade("2024.05.01", releases, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024.11.02", upgraded)
+	upgraded, err = (&Policy{Scheme: CalVer, Deny: []string{"2025.02.01"}}).Upgrade("2024.05.01", releases, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024.11.02", upgraded)
+
+	_, err = (&Policy{Scheme: Lexical, MaxDistance: DistanceMinor}).Upgrade("2024.05.01", releases, now)
+	assert.Error(t, err)
+}
@@ -15,7 +15,6 @@
 package semver
 
 import (
-	"fmt"
 	"sort"
 
 	version "github.com/Masterminds/semver/v3"
@@ -28,16 +27,11 @@ import (
 // date-based versions (e.g. '2023-11-11'), then ordering versions
 // without heuristics is impossible. To handle this we only accept
 // semver v2.0.0 versions with the only exception being a leading 'v'.
+// Use SortScheme for versions of other schemes.
 func Sort(versionsRaw []string) []*version.Version {
 	versions := make([]*version.Version, 0, len(versionsRaw))
 	for _, raw := range versionsRaw {
-		rawOrig := raw
-		if raw != "" && raw[0] == 'v' {
-			raw = raw[1:]
-		}
-		_, err := version.StrictNewVersion(raw) // Only accept semver-2
-		if err == nil {
-			v, _ := version.NewVersion(rawOrig) // Parse with optional leading 'v'
+		if v, err := parseSemVer(raw); err == nil {
 			versions = append(versions, v)
 		}
 	}
@@ -48,17 +42,7 @@ func Sort(versionsRaw []string) []*version.Version {
 
 // Upgrade returns the highest version from versions that fulfill constraint
 func Upgrade(versions []string, constraint string) (string, error) {
-	constraints, err := version.NewConstraint(constraint)
-	if err != nil {
-		return "", fmt.Errorf("error parsing constraint %q: %q", constraint, err.Error())
-	}
-	vers := Sort(versions)
-	for _, v := range vers {
-		if constraints.Check(v) {
-			return v.Original(), nil
-		}
-	}
-	return "", fmt.Errorf("no version found that satisfies constraint: %q", constraint)
+	return UpgradeScheme(SemVer, versions, constraint)
 }
 
 // Diff will calculate the difference between two semver
@@ -69,21 +53,5 @@ func Upgrade(versions []string, constraint string) (string, error) {
 // zeros.  E.g. the difference between `2.0.0' and '1.6.99' is
 // '1.0.0'
 func Diff(fromVer, toVer string) (string, error) {
-	from, err := version.NewVersion(fromVer)
-	if err != nil {
-		return "", err
-	}
-	to, err := version.NewVersion(toVer)
-	if err != nil {
-		return "", err
-	}
-	var major, minor, patch uint64
-	major = to.Major() - from.Major()
-	if major == 0 {
-		minor = to.Minor() - from.Minor()
-		if minor == 0 {
-			patch = to.Patch() - from.Patch()
-		}
-	}
-	return fmt.Sprintf("%d.%d.%d", major, minor, patch), nil
+	return DiffScheme(SemVer, fromVer, toVer)
 }
@@ -0,0 +1,114 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/krm-functions/catalog/pkg/api"
+)
+
+const (
+	ArgoCDSecretTypeLabel      = "argocd.argoproj.io/secret-type"
+	ArgoCDSecretTypeRepository = "repository"
+	ArgoCDSecretTypeRepoCreds  = "repo-creds"
+)
+
+// LookupArgoCDAuth looks up the credentials of the chart repository
+// of an Argo CD application. Credentials are given by, in order of precedence:
+//
+//   - The Secret named by the chart auth annotation of the application,
+//     as '<namespace>/<name>' or '<name>', with 'username' and 'password' keys.
+//   - An Argo CD repository Secret whose URL equals the repository URL.
+//   - The Argo CD repo-creds Secret whose URL is the longest prefix of the repository URL.
+//
+// Returns empty credentials if none are found
+func LookupArgoCDAuth(kubeObject *fn.KubeObject, repoURL string, rl *fn.ResourceList) (username, password string, err error) {
+	if auth := kubeObject.GetAnnotation(api.HelmResourceAnnotationAuth); auth != "" {
+		namespace, name, found := strings.Cut(auth, "/")
+		if !found {
+			namespace, name = "", auth
+		}
+		return LookupAuthSecret(name, namespace, rl)
+	}
+
+	var repoCreds *fn.KubeObject
+	var repoCredsURL string
+	for _, k := range rl.Items {
+		if !k.IsGVK("v1", "", "Secret") {
+			continue
+		}
+		secretType := k.GetLabel(ArgoCDSecretTypeLabel)
+		if secretType != ArgoCDSecretTypeRepository && secretType != ArgoCDSecretTypeRepoCreds {
+			continue
+		}
+		b, err := argoCDSecretData(k, "url")
+		if err != nil {
+			return "", "", err
+		}
+		url := normalizeArgoCDURL(string(b))
+		if url == "" {
+			continue
+		}
+		if secretType == ArgoCDSecretTypeRepository && url == normalizeArgoCDURL(repoURL) {
+			return argoCDCredentials(k)
+		}
+		if secretType == ArgoCDSecretTypeRepoCreds && strings.HasPrefix(normalizeArgoCDURL(repoURL), url) && len(url) > len(repoCredsURL) {
+			repoCreds, repoCredsURL = k, url
+		}
+	}
+	if repoCreds != nil {
+		return argoCDCredentials(repoCreds)
+	}
+	return "", "", nil
+}
+
+// normalizeArgoCDURL normalizes a repository URL for matching. OCI
+// repositories are given without the 'oci://' prefix in Argo CD
+func normalizeArgoCDURL(url string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(url), "oci://"), "/")
+}
+
+func argoCDCredentials(k *fn.KubeObject) (username, password string, err error) {
+	u, err := argoCDSecretData(k, "username")
+	if err != nil {
+		return "", "", err
+	}
+	p, err := argoCDSecretData(k, "password")
+	if err != nil {
+		return "", "", err
+	}
+	return string(u), string(p), nil
+}
+
+// argoCDSecretData returns the value of a key of an Argo CD Secret,
+// which is given either in 'stringData' or base64 encoded in 'data'
+func argoCDSecretData(k *fn.KubeObject, key string) ([]byte, error) {
+	if s, found, _ := k.NestedString("stringData", key); found {
+		return []byte(s), nil
+	}
+	s, found, err := k.NestedString("data", key)
+	if err != nil || !found {
+		return nil, err
+	}
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("decoding '%v' in Secret %s/%s: %w", key, k.GetNamespace(), k.GetName(), err)
+	}
+	return b, nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/krm-functions/catalog/pkg/api"
+	"github.com/stretchr/testify/assert"
+)
+
+const testArgoCDSecrets = `apiVersion: v1
+kind: Secret
+metadata:
+  name: creds-org
+  namespace: argocd
+  labels:
+    argocd.argoproj.io/secret-type: repo-creds
+stringData:
+  url: https://charts.example.com/
+  username: org
+  password: org-pw
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: creds-team
+  namespace: argocd
+  labels:
+    argocd.argoproj.io/secret-type: repo-creds
+stringData:
+  url: https://charts.example.com/team
+  username: team
+  password: team-pw
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: repo-team-a
+  namespace: argocd
+  labels:
+    argocd.argoproj.io/secret-type: repository
+data:
+  url: aHR0cHM6Ly9jaGFydHMuZXhhbXBsZS5jb20vdGVhbS9h
+  username: dGVhbS1h
+  password: dGVhbS1hLXB3
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: repo-oci
+  namespace: argocd
+  labels:
+    argocd.argoproj.io/secret-type: repository
+stringData:
+  url: registry.example.com/charts
+  username: oci
+  password: oci-pw
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: explicit
+  namespace: apps
+data:
+  username: ZXhwbGljaXQ=
+  password: ZXhwbGljaXQtcHc=
+`
+
+func TestLookupArgoCDAuth(t *testing.T) {
+	items, err := fn.ParseKubeObjects([]byte(testArgoCDSecrets))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl := &fn.ResourceList{Items: items}
+	app := fn.NewEmptyKubeObject()
+
+	tests := []struct {
+		repo, username, password string
+	}{
+		{"https://charts.example.com/team/a", "team-a", "team-a-pw"}, // Repository Secret
+		{"https://charts.example.com/team/b", "team", "team-pw"},     // Longest repo-creds prefix
+		{"https://charts.example.com/other", "org", "org-pw"},        // Shorter repo-creds prefix
+		{"oci://registry.example.com/charts", "oci", "oci-pw"},       // OCI repositories without 'oci://' in Argo CD
+		{"https://charts.other.com", "", ""},                         // No credentials
+	}
+	for _, tc := range tests {
+		username, password, err := LookupArgoCDAuth(app, tc.repo, rl)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.username, username, tc.repo)
+		assert.Equal(t, tc.password, password, tc.repo)
+	}
+
+	// Explicit auth takes precedence
+	assert.NoError(t, app.SetAnnotation(api.HelmResourceAnnotationAuth, "apps/explicit"))
+	username, password, err := LookupArgoCDAuth(app, "https://charts.example.com/team/a", rl)
+	assert.NoError(t, err)
+	assert.Equal(t, "explicit", username)
+	assert.Equal(t, "explicit-pw", password)
+
+	assert.NoError(t, app.SetAnnotation(api.HelmResourceAnnotationAuth, "apps/missing"))
+	_, _, err = LookupArgoCDAuth(app, "https://charts.example.com/team/a", rl)
+	assert.Error(t, err)
+}
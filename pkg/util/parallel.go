@@ -0,0 +1,77 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+)
+
+// DefaultWorkers is the default number of concurrent workers used by functions retrieving charts
+const DefaultWorkers = 4
+
+// ConfigWorkers returns the number of workers given by 'workers' of a
+// ConfigMap function config, as a number or a string, e.g. "8".
+// Defaults to DefaultWorkers
+func ConfigWorkers(configmap *fn.KubeObject) (int, error) {
+	data := configmap.GetMap("data")
+	if data == nil {
+		return DefaultWorkers, nil
+	}
+	workers, found, err := data.NestedInt("workers")
+	if err != nil {
+		val, _, sErr := data.NestedString("workers")
+		if sErr != nil {
+			return 0, fmt.Errorf("invalid workers: %w", err)
+		}
+		if workers, err = strconv.Atoi(val); err != nil {
+			return 0, fmt.Errorf("invalid workers %q: expected a number", val)
+		}
+	}
+	if !found {
+		return DefaultWorkers, nil
+	}
+	if workers < 1 {
+		return 0, fmt.Errorf("invalid workers %d: must be at least 1", workers)
+	}
+	return workers, nil
+}
+
+// ParallelMap calls f for each element of in, using at most workers
+// concurrent goroutines. Results and errors are returned in the order
+// of in, irrespective of the order in which f completes
+func ParallelMap[T, R any](in []T, workers int, f func(T) (R, error)) ([]R, []error) {
+	if workers < 1 {
+		workers = 1
+	}
+	out := make([]R, len(in))
+	errs := make([]error, len(in))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for idx := range in {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(idx int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			out[idx], errs[idx] = f(in[idx])
+		}(idx)
+	}
+	wg.Wait()
+	return out, errs
+}
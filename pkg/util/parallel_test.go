@@ -0,0 +1,74 @@
+// Copyright 2025 Michael Vittrup Larsen
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParallelMap(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	in := []int{5, 4, 3, 2, 1, 0}
+	out, errs := ParallelMap(in, 2, func(i int) (string, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Duration(i) * time.Millisecond)
+		if i == 3 {
+			return "", fmt.Errorf("failed %d", i)
+		}
+		return fmt.Sprint(i), nil
+	})
+	assert.Equal(t, []string{"5", "4", "", "2", "1", "0"}, out)
+	assert.NoError(t, errs[0])
+	assert.EqualError(t, errs[2], "failed 3")
+	assert.LessOrEqual(t, maxRunning.Load(), int32(2))
+}
+
+func TestConfigWorkers(t *testing.T) {
+	for workers, expected := range map[string]int{
+		"":                   DefaultWorkers,
+		"  other: value\n":   DefaultWorkers,
+		"  workers: 8\n":     8,
+		"  workers: \"8\"\n": 8,
+	} {
+		configmap, err := fn.ParseKubeObject([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cfg\ndata:\n" + workers))
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := ConfigWorkers(configmap)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, n)
+	}
+	for _, workers := range []string{`"many"`, `"0"`, "-1"} {
+		configmap, err := fn.ParseKubeObject([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cfg\ndata:\n  workers: " + workers + "\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = ConfigWorkers(configmap)
+		assert.Error(t, err, workers)
+	}
+}
@@ -17,12 +17,23 @@ import (
 	"encoding/base64"
 	"fmt"
 	"maps"
+	"os"
 	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
 )
 
+// OfflineModeEnv is the environment variable enabling offline mode, where functions resolve artifacts only from local mirrors
+const OfflineModeEnv = "OFFLINE_MODE"
+
+// OfflineMode returns true if offline mode is enabled
+func OfflineMode() bool {
+	offline, err := strconv.ParseBool(os.Getenv(OfflineModeEnv))
+	return err == nil && offline
+}
+
 func CsvToList(in string) []string {
 	lst := strings.Split(in, ",")
 	for idx, itm := range lst {
@@ -46,6 +57,11 @@ func LookupSSHAuthSecret(secretName, namespace string, rl *fn.ResourceList) (use
 	return LookupAuthSecretWithKeys(secretName, namespace, "ssh-username", "ssh-privatekey", rl)
 }
 
+// LookupKeyringSecret will lookup a secret in a resourcelist and return the PGP keyring decoded from secret
+func LookupKeyringSecret(secretName, namespace string, rl *fn.ResourceList) ([]byte, error) {
+	return LookupSecretData(secretName, namespace, "keyring", rl)
+}
+
 // LookupAuthSecretWithKeys will lookup a secret in a resourcelist and return username and password decoded from secret with the username and password being defined by supplied key names
 func LookupAuthSecretWithKeys(secretName, namespace, usernameKey, passwordKey string, rl *fn.ResourceList) (username, password string, err error) {
 	if namespace == "" {
@@ -99,6 +115,66 @@ func LookupAuthSecretWithKeys(secretName, namespace, usernameKey, passwordKey st
 	return
 }
 
+// LookupSecretData will lookup a secret in a resourcelist and return the data of the given key decoded from secret
+func LookupSecretData(secretName, namespace, key string, rl *fn.ResourceList) ([]byte, error) {
+	if namespace == "" {
+		namespace = "default" // Default according to spec
+	}
+	for _, k := range rl.Items {
+		if !k.IsGVK("v1", "", "Secret") || k.GetName() != secretName {
+			continue
+		}
+		oNamespace := k.GetNamespace()
+		if oNamespace == "" {
+			oNamespace = "default" // Default according to spec
+		}
+		if namespace != oNamespace {
+			continue
+		}
+		data, found, err := k.NestedString("data", key)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, fmt.Errorf("key '%v' not found in Secret %s/%s", key, namespace, secretName)
+		}
+		d, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return nil, fmt.Errorf("decoding '%v' in Secret %s/%s: %w", key, namespace, secretName, err)
+		}
+		return d, nil
+	}
+	return nil, fmt.Errorf("cannot find Secret %s/%s", namespace, secretName)
+}
+
+// LookupConfigMapData will lookup a configmap in a resourcelist and return the data of the given key
+func LookupConfigMapData(configMapName, namespace, key string, rl *fn.ResourceList) ([]byte, error) {
+	if namespace == "" {
+		namespace = "default" // Default according to spec
+	}
+	for _, k := range rl.Items {
+		if !k.IsGVK("v1", "", "ConfigMap") || k.GetName() != configMapName {
+			continue
+		}
+		oNamespace := k.GetNamespace()
+		if oNamespace == "" {
+			oNamespace = "default" // Default according to spec
+		}
+		if namespace != oNamespace {
+			continue
+		}
+		data, found, err := k.NestedString("data", key)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, fmt.Errorf("key '%v' not found in ConfigMap %s/%s", key, namespace, configMapName)
+		}
+		return []byte(data), nil
+	}
+	return nil, fmt.Errorf("cannot find ConfigMap %s/%s", namespace, configMapName)
+}
+
 // UniqueStrings removes duplicate strings from slice
 func UniqueStrings(list []string) []string {
 	slices.Sort(list)
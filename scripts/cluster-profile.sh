@@ -0,0 +1,33 @@
+#! /usr/bin/env bash
+
+# Generate a ClusterProfile for render-helm-chart from a kubeconform
+# schema bundle (see source-schemas.sh). API versions are derived from
+# the CRD schemas, named '<group>/<kind>_<version>.json'. Built-in
+# Kubernetes API versions are known by render-helm-chart and not listed.
+
+set -e
+
+NAME=$1
+KUBE_VERSION=$2
+CRD_SCHEMAS=${3:-schema-bundle/CRDs-catalog}
+
+if [[ -z "$NAME" || -z "$KUBE_VERSION" ]]; then
+	echo "Usage: $0 <profile name> <kube version> [CRD schema dir]" 1>&2
+	exit 1
+fi
+
+cat <<EOT
+apiVersion: experimental.helm.sh/v1alpha1
+kind: ClusterProfile
+metadata:
+  name: $NAME
+  annotations:
+    config.kubernetes.io/local-config: "true"
+kubeVersion: $KUBE_VERSION
+apiVersions:
+EOT
+find "$CRD_SCHEMAS" -mindepth 2 -maxdepth 2 -name '*_*.json' | while read -r f; do
+	group=$(basename "$(dirname "$f")")
+	file=$(basename "$f" .json)
+	echo "- $group/${file##*_}"
+done | sort -u